@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+const MaxUsers = 4096
+const MaxRooms = 1024
+const RoomCodeLength = 4
+
+// allocAttempts bounds the random draws per allocation. The limits above keep
+// the pools at most 1/16 full, so running out of attempts is practically
+// impossible unless the pool is at capacity.
+const allocAttempts = 64
+
+var ErrServerFull = errors.New("server is full, try again later")
+var ErrNoRoomsLeft = errors.New("no rooms available, try again later")
+
+// blockedRoomWords are never allowed to appear inside a room code
+var blockedRoomWords = []string{
+	"ANAL", "ANUS", "ARSE", "BOOB", "CLIT", "COCK", "CRAP", "CUNT", "DICK",
+	"DUMB", "FUCK", "HOMO", "JIZZ", "KIKE", "KILL", "NAZI", "NIGG", "PISS",
+	"POOP", "PORN", "RAPE", "SHIT", "SLUT", "SPIC", "TITS", "TWAT", "WANK",
+	"ASS", "CUM", "FAG", "FUK", "GAY", "KKK", "SEX", "TIT", "WTF",
+}
+
+// allocator hands out unique values drawn at random and takes them back once
+// they are freed. It is safe for concurrent use.
+type allocator[T comparable] struct {
+	mu     sync.Mutex
+	inUse  map[T]struct{}
+	limit  int
+	full   error
+	random func() T
+	valid  func(T) bool
+}
+
+// Allocate reserves a value that is not currently in use
+func (a *allocator[T]) Allocate() (T, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var zero T
+	if len(a.inUse) >= a.limit {
+		return zero, a.full
+	}
+
+	for i := 0; i < allocAttempts; i++ {
+		v := a.random()
+		if _, taken := a.inUse[v]; taken {
+			continue
+		}
+		if a.valid != nil && !a.valid(v) {
+			continue
+		}
+		a.inUse[v] = struct{}{}
+		return v, nil
+	}
+
+	return zero, a.full
+}
+
+// Free releases a value so it can be allocated again
+func (a *allocator[T]) Free(v T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.inUse, v)
+}
+
+// UserIDs allocates the IDs of connected users
+var UserIDs = &allocator[int16]{
+	inUse: map[int16]struct{}{},
+	limit: MaxUsers,
+	full:  ErrServerFull,
+	random: func() int16 {
+		return int16(rand.Int31() % 65536)
+	},
+}
+
+// RoomCodes allocates the codes of running games
+var RoomCodes = &allocator[string]{
+	inUse:  map[string]struct{}{},
+	limit:  MaxRooms,
+	full:   ErrNoRoomsLeft,
+	random: randomRoomCode,
+	valid:  isCleanRoomCode,
+}
+
+func randomRoomCode() string {
+	room := ""
+	for i := 0; i < RoomCodeLength; i++ {
+		room += string(rune(65 + rand.Intn(26)))
+	}
+	return room
+}
+
+func isCleanRoomCode(room string) bool {
+	for _, word := range blockedRoomWords {
+		if strings.Contains(room, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package entities
+
+import (
+	"online-game/msgs"
+	"online-game/types"
+	"time"
+)
+
+const (
+	MineEntity    types.EntityKind = iota
+	GrenadeEntity types.EntityKind = iota
+)
+
+// EntityStatus is what became of an entity after a tick
+type EntityStatus uint8
+
+const (
+	EntityAlive     EntityStatus = iota
+	EntityDone      EntityStatus = iota // despawned quietly
+	EntityTriggered EntityStatus = iota // went off where it stands, then despawned
+)
+
+// Entity is a world object that lives on the game apart from the players,
+// like a placed mine
+type Entity interface {
+	Kind() types.EntityKind
+	Team() types.TeamID
+	// Position is where the entity stands, in the same units as Player.X and
+	// Player.Y
+	Position() (x, y float64)
+	// SpawnArgs is the kind specific part of the entity's EntitySpawnedMessage
+	SpawnArgs() []byte
+	// Update runs on every tick of a match
+	Update(g *Game) EntityStatus
+}
+
+// spawned is an entity on the game
+type spawned struct {
+	Entity
+	id        uint16
+	expiresAt time.Time // zero for never
+}
+
+func (s *spawned) message() msgs.EntitySpawnedMessage {
+	x, y := s.Position()
+	return msgs.EntitySpawnedMessage{
+		EntityId: s.id,
+		Kind:     s.Kind(),
+		Team:     s.Team(),
+		X:        x,
+		Y:        y,
+		Args:     s.SpawnArgs(),
+	}
+}
+
+// Spawn adds the entity to the game and announces it. An entity with a
+// positive ttl is despawned once it runs out.
+func (g *Game) Spawn(e Entity, ttl time.Duration) uint16 {
+	g.entitySeq++
+	s := &spawned{Entity: e, id: g.entitySeq}
+	if ttl > 0 {
+		s.expiresAt = time.Now().Add(ttl)
+	}
+	g.entities = append(g.entities, s)
+	g.Broadcast(s.message())
+	return s.id
+}
+
+// CountEntities counts the entities of a kind on the game that match
+func (g *Game) CountEntities(kind types.EntityKind, match func(Entity) bool) int {
+	n := 0
+	for _, s := range g.entities {
+		if s.Kind() == kind && match(s.Entity) {
+			n++
+		}
+	}
+	return n
+}
+
+// updateEntities updates every entity and despawns the expired and finished
+// ones
+func (g *Game) updateEntities() {
+	now := time.Now()
+	alive := g.entities[:0]
+	for _, s := range g.entities {
+		status := EntityDone
+		if s.expiresAt.IsZero() || now.Before(s.expiresAt) {
+			status = s.Update(g)
+		}
+		switch status {
+		case EntityAlive:
+			alive = append(alive, s)
+			continue
+		case EntityTriggered:
+			x, y := s.Position()
+			g.Broadcast(msgs.EntityTriggeredMessage{EntityId: s.id, X: x, Y: y})
+		}
+		g.Broadcast(msgs.EntityDespawnedMessage{EntityId: s.id})
+	}
+	for i := len(alive); i < len(g.entities); i++ {
+		g.entities[i] = nil
+	}
+	g.entities = alive
+}
+
+// clearEntities despawns every entity
+func (g *Game) clearEntities() {
+	for _, s := range g.entities {
+		g.Broadcast(msgs.EntityDespawnedMessage{EntityId: s.id})
+	}
+	g.entities = nil
+}
+
+// sendEntities sends a single user every entity on the game
+func (g *Game) sendEntities(user *User) {
+	for _, s := range g.entities {
+		user.SendMessage(s.message())
+	}
+}
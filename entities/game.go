@@ -1,38 +1,53 @@
 package entities
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/rand"
 	"online-game/msgs"
 	"online-game/types"
+	"sync"
 	"time"
 )
 
 const TickRate = 30
 const GameTick = time.Millisecond * 1000 / TickRate
-const MapTick = TickRate * 5 // every 5 seconds
 
-const MaxPlayers = 8
-const GameDuration = 60 * time.Second
-const PlayerSpeed = 10
+// StateHistory is how many state snapshots are kept as delta baselines
+const StateHistory = 32
 
-const MapWidth = 48
-const MapHeight = 27
+// MaxPlayers is the most players any room can hold, see MatchSettings for
+// the limit of a given room
+const MaxPlayers = 8
 
-// Game represents a game session
+// Game represents a game session.
+//
+// Every game owns a goroutine (see run) that processes ticks and queued
+// commands one at a time. Code running outside that goroutine must only touch
+// the game through Do.
 type Game struct {
-	Players Players
-	State   types.GameState
-	Host    int16
-	Room    string
-	LC      bool // large change
+	Players  Players
+	State    types.GameState
+	Host     int16
+	Room     string
+	Settings MatchSettings
+	LC       bool // large change
 
 	Started   bool
 	StartedAt time.Time
-}
 
-var Games = []*Game{}
+	seq     uint32 // sequence number of the last state snapshot
+	history [StateHistory]msgs.StateMessage
+	journal types.TileJournal
+
+	entities  []*spawned
+	entitySeq uint16 // id of the last spawned entity
+
+	cmds     chan func()
+	done     chan struct{}
+	doneOnce sync.Once
+}
 
 type CellResult struct {
 	X     int
@@ -41,49 +56,83 @@ type CellResult struct {
 }
 
 // NewGame creates a new game and returns the room code
-func NewGame(host *User, wepon *Weapon) string {
-	room := "" // a random string
-	for i := 0; i < 4; i++ {
-		room += string(rune(65 + rand.Intn(26)))
+func NewGame(host *User, loadout Loadout) (string, error) {
+	room, err := RoomCodes.Allocate()
+	if err != nil {
+		return "", err
 	}
-	player := host.ToPlayer(TeamA, wepon)
+	player := host.ToPlayer(TeamA, loadout)
+	settings := DefaultMatchSettings()
 	game := &Game{
 		Players: Players{
 			player,
 		},
-		State: *NewGameState(MapWidth, MapHeight),
-		Host:  host.ID,
-		Room:  room,
-		LC:    true,
-	}
-	Games = append(Games, game)
-	return room
+		State:    *NewGameState(settings),
+		Host:     host.ID,
+		Room:     room,
+		Settings: settings,
+		LC:       true,
+		cmds:     make(chan func()),
+		done:     make(chan struct{}),
+	}
+	game.State.GameMap.Journal = &game.journal
+	host.SendMessage(settings.Message())
+	Games.Add(game)
+	Games.Bind(host.ID, game)
+	go game.run()
+	return room, nil
 }
 
-// FindGameByRoom finds a game by its room code
-func FindGameByRoom(room string) *Game {
-	for _, game := range Games {
-		if game.Room == room {
-			return game
+// run is the game's goroutine. It serializes ticks and commands until the game
+// is terminated.
+func (g *Game) run() {
+	ticker := time.NewTicker(GameTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cmd := <-g.cmds:
+			cmd()
+		case <-ticker.C:
+			g.tick()
+		case <-g.done:
+			return
 		}
 	}
-	return nil
 }
 
-// FindUserInfo finds the game a user is in
-func FindUserInfo(userId int16) *Game {
-	for _, game := range Games {
-		for _, player := range game.Players {
-			if player.User.ID == userId {
-				return game
-			}
-		}
+// Do runs fn on the game's goroutine and waits for it to return.
+// It reports false without running fn if the game has been terminated.
+// Do must not be called from the game's own goroutine.
+func (g *Game) Do(fn func()) bool {
+	finished := make(chan struct{})
+	cmd := func() {
+		defer close(finished)
+		fn()
 	}
-	return nil
+
+	select {
+	case g.cmds <- cmd:
+	case <-g.done:
+		return false
+	}
+	<-finished
+	return true
 }
 
-func (g *Game) AddUser(user *User, weapon *Weapon) error {
-	if len(g.Players) >= MaxPlayers {
+// tick advances the game by one GameTick and broadcasts the changes
+func (g *Game) tick() {
+	g.Update()
+	g.FlushTiles()
+
+	if g.State.Phase == Playing || g.LC {
+		g.BroadcastState()
+		g.LC = false
+	}
+}
+
+func (g *Game) AddUser(user *User, loadout Loadout) error {
+	if len(g.Players) >= g.Settings.MaxPlayers {
 		return errors.New("game is full")
 	}
 
@@ -97,13 +146,33 @@ func (g *Game) AddUser(user *User, weapon *Weapon) error {
 		newTeam = TeamB
 	}
 
-	player := user.ToPlayer(newTeam, weapon)
+	player := user.ToPlayer(newTeam, loadout)
 	g.Players = append(g.Players, player)
+	Games.Bind(user.ID, g)
+	user.SendMessage(g.Settings.Message())
+	g.SendMap(user)
 	g.LC = true
 
 	return nil
 }
 
+// Rejoin brings a resumed player back up to date, with the full map and a
+// full state snapshot on the next tick
+func (g *Game) Rejoin(user *User) {
+	player := g.GetPlayer(user.ID)
+	if player == nil {
+		return
+	}
+	player.AckedSeq = 0
+	// a reloaded client numbers its inputs from scratch
+	player.InputSeq = 0
+	user.SendMessage(msgs.JoinedMessage{Room: g.Room})
+	user.SendMessage(g.Settings.Message())
+	g.Resync(user)
+	g.LC = true
+	g.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s reconnected", user.Username))
+}
+
 func (g *Game) RemovePlayer(userId int16) {
 	for i, p := range g.Players {
 		if p.User.ID == userId {
@@ -111,10 +180,12 @@ func (g *Game) RemovePlayer(userId int16) {
 			break
 		}
 	}
+	Games.Unbind(userId, g)
 	if len(g.Players) == 0 {
 		g.Terminate()
 	} else if len(g.Players) < 2 {
 		g.State.Phase = WaitingForPlayers
+		g.clearEntities()
 		Clear(&g.State.GameMap)
 		if g.Host == userId {
 			g.Host = g.Players[0].User.ID
@@ -180,10 +251,19 @@ func (g *Game) Start(userId int16) error {
 		return errors.New("need at least one player on each team")
 	}
 
+	for _, player := range g.Players {
+		for _, weapon := range player.Loadout {
+			if !g.Settings.Allows(weapon.Id()) {
+				return fmt.Errorf("%s's %s is not allowed in this room", player.User.Username, weapon.Name())
+			}
+		}
+	}
+
 	if g.State.Phase == WaitingForPlayers { // First game
 		Clear(&g.State.GameMap)
 	} else {
-		g.State = *NewGameState(MapWidth, MapHeight)
+		g.State = *NewGameState(g.Settings)
+		g.State.GameMap.Journal = &g.journal
 	}
 
 	g.BroadcastMap()
@@ -193,22 +273,111 @@ func (g *Game) Start(userId int16) error {
 	g.StartedAt = time.Now()
 
 	for _, player := range g.Players {
-		for {
-			player.X = rand.Float64() * float64(g.State.GameMap.Width)
-			player.Y = rand.Float64() * float64(g.State.GameMap.Height)
-			if Get(&g.State.GameMap, int(player.X), int(player.Y)) != WallTile {
-				break
+		player.Ink = MaxInk
+		g.spawn(player)
+	}
+
+	return nil
+}
+
+// spawn puts the player on a random tile of their team's spawn zone, or of
+// the whole map when it has none. Every tile that is not a wall can reach the
+// others, so any of them will do.
+func (g *Game) spawn(player *Player) {
+	gm := &g.State.GameMap
+	var zone []Cell
+	if m := g.Settings.PoolMap(); m != nil {
+		zone = m.Spawns[player.Team]
+	} else {
+		zone = SpawnZones(gm, g.Settings.Symmetry)[player.Team]
+	}
+	if len(zone) == 0 {
+		for i, tile := range gm.Tiles {
+			if tile != WallTile {
+				zone = append(zone, Cell{i % gm.Width, i / gm.Width})
 			}
 		}
 	}
 
+	cell := zone[rand.Intn(len(zone))]
+	player.X, player.Y = float64(cell.X), float64(cell.Y)
+}
+
+// Configure replaces the match settings, regenerating the map when its shape
+// changed
+func (g *Game) Configure(userId int16, settings MatchSettings) error {
+	if g.State.Phase != WaitingForPlayers {
+		return errors.New("settings can only be changed before the game starts")
+	}
+
+	if g.Host != userId {
+		return errors.New("only the host can change the settings")
+	}
+
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	if settings.MaxPlayers < len(g.Players) {
+		return fmt.Errorf("there are already %d players in the room", len(g.Players))
+	}
+
+	if !settings.sameMap(g.Settings) {
+		g.State = *NewGameState(settings)
+		g.State.GameMap.Journal = &g.journal
+		g.BroadcastMap()
+	}
+	g.Settings = settings
+	g.Broadcast(settings.Message())
+	g.LC = true
+
+	return nil
+}
+
+// Arm puts the weapon the player picked in the lobby in the slot
+func (g *Game) Arm(userId int16, slot types.Slot, weapon Weapon) error {
+	if g.State.Phase != WaitingForPlayers {
+		return errors.New("game has already started")
+	}
+
+	player := g.GetPlayer(userId)
+	if player == nil {
+		return errors.New("player not found")
+	}
+
+	if slot >= types.LoadoutSize {
+		return errors.New("invalid weapon slot")
+	}
+
+	if !g.Settings.Allows(weapon.Id()) {
+		return fmt.Errorf("%s is not allowed in this room", weapon.Name())
+	}
+
+	player.Loadout[slot] = weapon
+	g.LC = true
+
+	return nil
+}
+
+// Swap switches the player to the weapon in their other slot
+func (g *Game) Swap(userId int16) error {
+	player := g.GetPlayer(userId)
+	if player == nil {
+		return errors.New("player not found")
+	}
+
+	if err := player.Swap(); err != nil {
+		return err
+	}
+	g.LC = true
+
 	return nil
 }
 
-func (g *Game) MovePlayer(userId int16, direction string, start bool) {
+func (g *Game) MovePlayer(userId int16, seq uint32, input Input) {
 	player := g.GetPlayer(userId)
 	if player != nil {
-		player.Move(direction, start)
+		player.Move(seq, input)
 	}
 }
 
@@ -225,6 +394,9 @@ func (g *Game) Shoot(userId int16) (CellResult, error) {
 	if curr == WallTile {
 		return CellResult{}, errors.New("cannot paint wall")
 	}
+	if err := player.UseInk(ShootInk); err != nil {
+		return CellResult{}, err
+	}
 	switch curr {
 	case TeamATile:
 		g.State.ScoreA--
@@ -250,17 +422,46 @@ func (g *Game) Shoot(userId int16) (CellResult, error) {
 	}, nil
 }
 
+// Paint paints the tile in the team's color and keeps the scores. It reports
+// whether the tile changed, walls and tiles the team owns are left alone.
+func (g *Game) Paint(x, y int, team types.TeamID) bool {
+	newTile := TeamATile
+	if team == TeamB {
+		newTile = TeamBTile
+	}
+
+	curr := Get(&g.State.GameMap, x, y)
+	if curr == WallTile || curr == newTile {
+		return false
+	}
+	switch curr {
+	case TeamATile:
+		g.State.ScoreA--
+	case TeamBTile:
+		g.State.ScoreB--
+	}
+	switch newTile {
+	case TeamATile:
+		g.State.ScoreA++
+	case TeamBTile:
+		g.State.ScoreB++
+	}
+	Set(&g.State.GameMap, x, y, newTile)
+
+	return true
+}
+
 // Update updates the game state
 func (g *Game) Update() {
 	if g.State.Phase != Playing {
 		return
 	}
 
-	gameMap := g.State.GameMap
 	for _, player := range g.Players {
-		player.Update(&gameMap)
+		player.Update(g)
 	}
-	if time.Since(g.StartedAt) > GameDuration {
+	g.updateEntities()
+	if time.Since(g.StartedAt) > g.Settings.Duration {
 		g.Finish()
 	}
 }
@@ -272,22 +473,26 @@ func (g *Game) Finish() {
 	for _, player := range g.Players {
 		player.Reset()
 	}
+	g.clearEntities()
 	g.BroadcastSystem(msgs.SYS_MSG_INFO, "Game over")
 	g.LC = true
 }
 
-// Terminate terminates the game
+// Terminate terminates the game and stops its goroutine
 func (g *Game) Terminate() {
-	for i, game := range Games {
-		if game == g {
-			Games = append(Games[:i], Games[i+1:]...)
-			break
-		}
-	}
+	g.doneOnce.Do(func() {
+		Games.Remove(g)
+		RoomCodes.Free(g.Room)
+		close(g.done)
+	})
 }
 
 func (g *Game) Broadcast(message msgs.ServerMessage, exclude ...int16) {
-	b, _ := message.Buffer()
+	b, ok := message.Buffer()
+	if !ok {
+		fmt.Printf("Failed to marshal %T\n", message)
+		return
+	}
 	buf := b.Bytes()
 
 PlayerLoop:
@@ -297,19 +502,68 @@ PlayerLoop:
 				continue PlayerLoop
 			}
 		}
+		player.User.Debug(message)
 		player.User.Send(buf)
 	}
 }
 
+// BroadcastMap sends everyone the full map, superseding any journaled changes
 func (g *Game) BroadcastMap(exclude ...int16) {
+	g.journal.Changed = g.journal.Changed[:0]
 	g.Broadcast(msgs.MapMessage{
 		Map: g.State.GameMap,
+	}, exclude...)
+}
+
+// SendMap sends a single user the full map
+func (g *Game) SendMap(user *User) {
+	user.SendMessage(msgs.MapMessage{
+		Map: g.State.GameMap,
 	})
 }
 
+// Resync sends a single user the full map and the entities on it, which the
+// client drops when it gets a map
+func (g *Game) Resync(user *User) {
+	g.SendMap(user)
+	g.sendEntities(user)
+}
+
+// FlushTiles broadcasts the tiles changed since the last flush in one message
+func (g *Game) FlushTiles() {
+	if len(g.journal.Changed) == 0 {
+		return
+	}
+
+	m := &g.State.GameMap
+	seen := make(map[int]bool, len(g.journal.Changed))
+	tiles := make([]types.TileChange, 0, len(g.journal.Changed))
+	for _, i := range g.journal.Changed {
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		tiles = append(tiles, types.TileChange{
+			X:    i % m.Width,
+			Y:    i / m.Width,
+			Tile: m.Tiles[i],
+		})
+	}
+	g.journal.Changed = g.journal.Changed[:0]
+
+	g.Broadcast(msgs.TilesMessage{
+		Checksum: Checksum(m),
+		Tiles:    tiles,
+	})
+}
+
+// BroadcastState takes a new state snapshot and sends every player the delta
+// from the last snapshot they acknowledged, or the full snapshot if there is
+// none to build on.
 func (g *Game) BroadcastState(exclude ...int16) {
-	// fmt.Printf("Started At: %v\r\nUnix: %d\r\n int32: %d\r\n", g.StartedAt, int32(g.StartedAt.Unix()), int32(g.StartedAt.Unix()))
-	g.Broadcast(msgs.StateMessage{
+	g.seq++
+	snapshot := msgs.StateMessage{
+		Seq:       g.seq,
 		Host:      g.Host,
 		Room:      g.Room,
 		Started:   g.Started,
@@ -322,7 +576,58 @@ func (g *Game) BroadcastState(exclude ...int16) {
 			Phase:  g.State.Phase,
 		},
 		Players: g.Players.Foo(),
-	})
+	}
+	g.history[g.seq%StateHistory] = snapshot
+
+	// players acknowledging the same baseline share the encoded message
+	encoded := map[uint32][]byte{}
+
+PlayerLoop:
+	for _, player := range g.Players {
+		for _, ex := range exclude {
+			if player.User.ID == ex {
+				continue PlayerLoop
+			}
+		}
+
+		base := player.AckedSeq
+		if g.seq-base >= StateHistory || !player.User.Protocol.Has(msgs.FEATURE_DELTA_STATE) {
+			base = 0
+		}
+
+		buf, ok := encoded[base]
+		if !ok {
+			var b *bytes.Buffer
+			if base == 0 {
+				b, ok = snapshot.Buffer()
+			} else {
+				b, ok = msgs.StateDeltaMessage{
+					Base:  g.history[base%StateHistory],
+					State: snapshot,
+				}.Buffer()
+			}
+			if !ok {
+				fmt.Println("Failed to marshal state message")
+				return
+			}
+			buf = b.Bytes()
+			encoded[base] = buf
+		}
+		player.User.Debug(snapshot)
+		player.User.Send(buf)
+	}
+}
+
+// AckState records that a player has received the state snapshot seq
+func (g *Game) AckState(userId int16, seq uint32) {
+	player := g.GetPlayer(userId)
+	if player == nil {
+		return
+	}
+	if seq > g.seq || seq <= player.AckedSeq {
+		return
+	}
+	player.AckedSeq = seq
 }
 
 func (g *Game) BroadcastSystem(msgType uint8, msg string, exclude ...int16) {
@@ -0,0 +1,140 @@
+package entities
+
+import (
+	"online-game/msgs"
+	"online-game/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWeapon is a weapon that does nothing, the weapons live in the wepons
+// package which imports this one
+type testWeapon struct{}
+
+func (testWeapon) Id() types.WeaponId        { return GrenadeId }
+func (testWeapon) Name() string              { return "Test" }
+func (testWeapon) GetCooldown() int          { return 0 }
+func (testWeapon) GetCooldownLeft() float64  { return 0 }
+func (testWeapon) GetCharge() float64        { return 0 }
+func (testWeapon) GetAmmo() float64          { return 1 }
+func (testWeapon) Stringify() map[string]any { return nil }
+func (testWeapon) ParseWeaponDownMessage(args []byte) (WeaponEvent, bool) {
+	return nil, false
+}
+func (testWeapon) ParseWeaponUpdateMessage(args []byte) (WeaponEvent, bool) {
+	return nil, false
+}
+func (testWeapon) ParseWeaponUpMessage(args []byte) (WeaponEvent, bool) {
+	return nil, false
+}
+
+func testLoadout() Loadout {
+	return Loadout{testWeapon{}, testWeapon{}}
+}
+
+func newTestUser(t *testing.T, name string) *User {
+	user, err := NewUser(nil, name, msgs.Negotiated{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// waitOrFail fails the test when wg is not done within a few seconds, so a
+// deadlock shows up as a failure instead of a hung test
+func waitOrFail(t *testing.T, wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the game's callers")
+	}
+}
+
+// TestGameConcurrentPlayers joins, moves, shoots and leaves from many
+// goroutines at once, all through Do, to be run with -race
+func TestGameConcurrentPlayers(t *testing.T) {
+	host := newTestUser(t, "host")
+	defer host.Cleanup()
+	room, err := NewGame(host, testLoadout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := Games.ByRoom(room)
+	defer game.Terminate()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user := newTestUser(t, "player")
+			game.Do(func() {
+				game.AddUser(user, testLoadout())
+			})
+			for seq := uint32(1); seq <= 50; seq++ {
+				game.Do(func() {
+					game.MovePlayer(user.ID, seq, Input{Right: seq%2 == 0, Down: seq%3 == 0})
+				})
+				game.Do(func() {
+					game.Shoot(user.ID)
+				})
+			}
+			user.Cleanup()
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			game.Do(func() {
+				game.Start(host.ID)
+			})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	waitOrFail(t, &wg)
+}
+
+// TestGameTerminateWithPendingDo terminates a game while other goroutines
+// are waiting on Do, which must all return
+func TestGameTerminateWithPendingDo(t *testing.T) {
+	host := newTestUser(t, "host")
+	defer host.Cleanup()
+	room, err := NewGame(host, testLoadout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := Games.ByRoom(room)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for game.Do(func() {
+				game.MovePlayer(host.ID, 1, Input{Left: true})
+				time.Sleep(100 * time.Microsecond)
+			}) {
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	game.Terminate()
+	waitOrFail(t, &wg)
+
+	if game.Do(func() { t.Error("ran after Terminate") }) {
+		t.Fatal("Do reported true after Terminate")
+	}
+	if Games.ByRoom(room) != nil {
+		t.Fatal("terminated game still registered")
+	}
+}
@@ -0,0 +1,200 @@
+package entities
+
+import (
+	"math/rand"
+	"online-game/types"
+)
+
+// MapGenerator draws the walls of a generated map within the range x1, y1 to
+// x2, y2 of its tiles, which are all empty to begin with. The map is
+// connected afterwards, the generator does not have to.
+type MapGenerator interface {
+	Generate(m *types.GameMap, x1, y1, x2, y2 int, r *rand.Rand)
+}
+
+type GeneratorId uint8
+
+const (
+	BSPGenerator   GeneratorId = iota
+	CaveGenerator  GeneratorId = iota
+	MazeGenerator  GeneratorId = iota
+	GeneratorCount GeneratorId = iota
+)
+
+// Tuning of the cave generator
+const (
+	caveFill    = 45 // percent of the tiles that start as walls
+	caveSteps   = 4  // smoothing steps
+	caveBirth   = 5  // least walls around a tile to turn it into a wall
+	caveSurvive = 4  // least walls around a wall to keep it
+	caveOpen    = 35 // least percent of open tiles in a cave
+	caveTries   = 10 // caves generated before leaving the range empty
+)
+
+// Tuning of the maze generator
+const (
+	mazeCorridor = 2  // tiles, the width of the corridors
+	mazeLoops    = 15 // percent of the walls between cells carved into loops
+)
+
+// BSP splits the range in two with a wall line, then each part again, as
+// deep as Divisions
+type BSP struct {
+	Divisions int
+}
+
+func (g BSP) Generate(m *types.GameMap, x1, y1, x2, y2 int, r *rand.Rand) {
+	generateWallsInRange(m, x1, y1, x2, y2, g.Divisions, r)
+}
+
+// Cave scatters walls at random, then smooths them with a cellular automaton
+// into rounded caves. Caves with too little room are thrown away and tried
+// again.
+type Cave struct{}
+
+func (g Cave) Generate(m *types.GameMap, x1, y1, x2, y2 int, r *rand.Rand) {
+	width, height := x2-x1+1, y2-y1+1
+	for try := 0; try < caveTries; try++ {
+		walls := cave(m, x1, y1, width, height, r)
+		open := 0
+		for _, wall := range walls {
+			if !wall {
+				open++
+			}
+		}
+		if open*100 < len(walls)*caveOpen {
+			continue
+		}
+		for i, wall := range walls {
+			if wall {
+				Set(m, x1+i%width, y1+i/width, WallTile)
+			}
+		}
+		return
+	}
+}
+
+// cave is the walls of one try of the cave generator in the range of width
+// by height tiles from x1, y1
+func cave(m *types.GameMap, x1, y1, width, height int, r *rand.Rand) []bool {
+	walls := make([]bool, width*height)
+	for i := range walls {
+		walls[i] = r.Intn(100) < caveFill
+	}
+
+	// tiles out of the map count as walls, so the caves close along its
+	// edges. Tiles in the map but out of the range, past a mirror seam, count
+	// as the tiles facing them across it, so the seam does not close.
+	reflect := func(v, lo, hi int) int {
+		if v < lo {
+			return 2*lo - 1 - v
+		}
+		if v > hi {
+			return 2*hi + 1 - v
+		}
+		return v
+	}
+	around := func(x, y int) int {
+		n := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+				nx, ny := x1+x+dx, y1+y+dy
+				if nx < 0 || nx >= m.Width || ny < 0 || ny >= m.Height {
+					n++
+					continue
+				}
+				nx, ny = reflect(nx, x1, x1+width-1), reflect(ny, y1, y1+height-1)
+				if walls[(ny-y1)*width+nx-x1] {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	for step := 0; step < caveSteps; step++ {
+		next := make([]bool, len(walls))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				n := around(x, y)
+				next[y*width+x] = n >= caveBirth || (walls[y*width+x] && n >= caveSurvive)
+			}
+		}
+		walls = next
+	}
+	return walls
+}
+
+// Maze lays a grid of cells and carves a maze through them, then carves some
+// of the walls left between cells so the maze has loops. Tiles past the last
+// cell are walls.
+type Maze struct{}
+
+func (g Maze) Generate(m *types.GameMap, x1, y1, x2, y2 int, r *rand.Rand) {
+	// a cell is its corridor and the wall after it, the last one has none
+	step := mazeCorridor + 1
+	cols, rows := (x2-x1+2)/step, (y2-y1+2)/step
+	if cols < 1 || rows < 1 {
+		return
+	}
+
+	for y := y1; y <= y2; y++ {
+		for x := x1; x <= x2; x++ {
+			Set(m, x, y, WallTile)
+		}
+	}
+
+	// carve clears the tiles of cell (i, j), and the wall towards (i+dx, j+dy)
+	carve := func(i, j, dx, dy int) {
+		for y := 0; y < mazeCorridor+dy; y++ {
+			for x := 0; x < mazeCorridor+dx; x++ {
+				Set(m, x1+i*step+x, y1+j*step+y, EmptyTile)
+			}
+		}
+	}
+
+	// depth-first walk from the first cell, carving towards every unvisited
+	// neighbour
+	visited := make([]bool, cols*rows)
+	visited[0] = true
+	carve(0, 0, 0, 0)
+	stack := []Cell{{0, 0}}
+	for len(stack) > 0 {
+		cell := stack[len(stack)-1]
+		var next []Cell
+		for _, n := range []Cell{{cell.X + 1, cell.Y}, {cell.X - 1, cell.Y}, {cell.X, cell.Y + 1}, {cell.X, cell.Y - 1}} {
+			if n.X >= 0 && n.X < cols && n.Y >= 0 && n.Y < rows && !visited[n.Y*cols+n.X] {
+				next = append(next, n)
+			}
+		}
+		if len(next) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		n := next[r.Intn(len(next))]
+		visited[n.Y*cols+n.X] = true
+		carve(n.X, n.Y, 0, 0)
+		// the wall is after the cell closer to the range's start
+		if n.X < cell.X || n.Y < cell.Y {
+			carve(n.X, n.Y, cell.X-n.X, cell.Y-n.Y)
+		} else {
+			carve(cell.X, cell.Y, n.X-cell.X, n.Y-cell.Y)
+		}
+		stack = append(stack, n)
+	}
+
+	// loops
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			if i+1 < cols && r.Intn(100) < mazeLoops {
+				carve(i, j, 1, 0)
+			}
+			if j+1 < rows && r.Intn(100) < mazeLoops {
+				carve(i, j, 0, 1)
+			}
+		}
+	}
+}
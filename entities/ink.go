@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"errors"
+	"math"
+)
+
+// Ink is what players paint with: shooting and weapons use it up, and it
+// refills over time
+const (
+	MaxInk          = 100
+	InkRefill       = 8  // per second
+	InkRefillOnTeam = 20 // per second, while standing on a tile of the player's team
+	ShootInk        = 2  // used by every shot
+)
+
+var ErrOutOfInk = errors.New("out of ink")
+
+// UseInk takes amount from the player's ink, or returns ErrOutOfInk without
+// taking any if there is not enough
+func (p *Player) UseInk(amount float64) error {
+	if p.Ink < amount {
+		return ErrOutOfInk
+	}
+	p.Ink -= amount
+	return nil
+}
+
+// refillInk refills the player's ink by a tick, faster on their team's tiles
+func (p *Player) refillInk(g *Game) {
+	rate := float64(InkRefill)
+	tile := Get(&g.State.GameMap, int(p.X+0.5), int(p.Y+0.5))
+	if (tile == TeamATile && p.Team == TeamA) || (tile == TeamBTile && p.Team == TeamB) {
+		rate = InkRefillOnTeam
+	}
+	p.Ink = math.Min(MaxInk, p.Ink+rate*GameTick.Seconds())
+}
@@ -0,0 +1,170 @@
+package entities
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"online-game/types"
+	"path"
+)
+
+// Characters of the rows of a map file
+const (
+	emptyRune  = '.'
+	wallRune   = '#'
+	spawnARune = 'a' // an empty tile team A spawns on
+	spawnBRune = 'b' // an empty tile team B spawns on
+)
+
+// MapFile is the JSON format of a map, its tiles are a string per row made of
+// the characters above
+type MapFile struct {
+	Name   string   `json:"name"`
+	Author string   `json:"author"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Tiles  []string `json:"tiles"`
+}
+
+// Map is a map loaded from a map file
+type Map struct {
+	Name    string
+	Author  string
+	GameMap types.GameMap
+	Spawns  [2][]Cell // the spawn zone of each team
+}
+
+//go:embed maps/*.json
+var mapFiles embed.FS
+
+// MapPool are the curated maps a host can pick instead of a generated one,
+// ordered by file name
+var MapPool []*Map
+
+func init() {
+	entries, err := mapFiles.ReadDir("maps")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := mapFiles.ReadFile(path.Join("maps", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		m, err := LoadMap(data)
+		if err != nil {
+			panic(fmt.Sprintf("map %s: %v", entry.Name(), err))
+		}
+		MapPool = append(MapPool, m)
+	}
+}
+
+// LoadMap reads a map file and validates it
+func LoadMap(data []byte) (*Map, error) {
+	var file MapFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	if file.Name == "" {
+		return nil, errors.New("map has no name")
+	}
+	if err := checkMapSize(file.Width, file.Height); err != nil {
+		return nil, err
+	}
+	if len(file.Tiles) != file.Height {
+		return nil, fmt.Errorf("map has %d rows, not %d", len(file.Tiles), file.Height)
+	}
+
+	m := &Map{
+		Name:   file.Name,
+		Author: file.Author,
+		GameMap: types.GameMap{
+			Width:  file.Width,
+			Height: file.Height,
+			Tiles:  make([]types.Tile, file.Width*file.Height),
+		},
+	}
+	for y, row := range file.Tiles {
+		if len(row) != file.Width {
+			return nil, fmt.Errorf("row %d has %d tiles, not %d", y, len(row), file.Width)
+		}
+		for x := 0; x < len(row); x++ {
+			switch row[x] {
+			case emptyRune:
+				Set(&m.GameMap, x, y, EmptyTile)
+			case wallRune:
+				Set(&m.GameMap, x, y, WallTile)
+			case spawnARune:
+				Set(&m.GameMap, x, y, EmptyTile)
+				m.Spawns[TeamA] = append(m.Spawns[TeamA], Cell{x, y})
+			case spawnBRune:
+				Set(&m.GameMap, x, y, EmptyTile)
+				m.Spawns[TeamB] = append(m.Spawns[TeamB], Cell{x, y})
+			default:
+				return nil, fmt.Errorf("invalid tile %q at %d,%d", row[x], x, y)
+			}
+		}
+	}
+
+	if err := ValidateMap(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ValidateMap checks a map can be played: its size is within the settings'
+// bounds, it only has empty and wall tiles, both teams have a spawn zone and
+// every empty tile can be walked to from every other
+func ValidateMap(m *Map) error {
+	gm := &m.GameMap
+	if err := checkMapSize(gm.Width, gm.Height); err != nil {
+		return err
+	}
+	if len(gm.Tiles) != gm.Width*gm.Height {
+		return errors.New("map tiles do not match its size")
+	}
+
+	var start *Cell
+	empty := 0
+	for i, tile := range gm.Tiles {
+		switch tile {
+		case EmptyTile:
+			if start == nil {
+				start = &Cell{i % gm.Width, i / gm.Width}
+			}
+			empty++
+		case WallTile:
+		default:
+			return fmt.Errorf("invalid tile %d at %d,%d", tile, i%gm.Width, i/gm.Width)
+		}
+	}
+
+	for _, team := range []types.TeamID{TeamA, TeamB} {
+		if len(m.Spawns[team]) == 0 {
+			return fmt.Errorf("team %d has no spawn zone", team)
+		}
+		for _, cell := range m.Spawns[team] {
+			if Get(gm, cell.X, cell.Y) != EmptyTile {
+				return fmt.Errorf("spawn at %d,%d is not an empty tile", cell.X, cell.Y)
+			}
+		}
+	}
+
+	if reached := len(Reachable(gm, *start)); reached != empty {
+		return fmt.Errorf("%d of the %d empty tiles cannot be reached", empty-reached, empty)
+	}
+	return nil
+}
+
+// checkMapSize checks a map's size is within the settings' bounds
+func checkMapSize(width, height int) error {
+	if width < MinMapWidth || width > MaxMapWidth {
+		return fmt.Errorf("map width must be between %d and %d", MinMapWidth, MaxMapWidth)
+	}
+	if height < MinMapHeight || height > MaxMapHeight {
+		return fmt.Errorf("map height must be between %d and %d", MinMapHeight, MaxMapHeight)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// validRows is a map of the smallest size with a spawn zone for each team
+func validRows() []string {
+	rows := make([]string, MinMapHeight)
+	for y := range rows {
+		rows[y] = strings.Repeat(".", MinMapWidth)
+	}
+	rows[0] = "a" + rows[0][1:]
+	rows[MinMapHeight-1] = rows[MinMapHeight-1][1:] + "b"
+	return rows
+}
+
+func mapJSON(width, height int, rows []string) string {
+	return fmt.Sprintf(`{"name":"test","width":%d,"height":%d,"tiles":["%s"]}`, width, height, strings.Join(rows, `","`))
+}
+
+func TestLoadMapValid(t *testing.T) {
+	m, err := LoadMap([]byte(mapJSON(MinMapWidth, MinMapHeight, validRows())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Spawns[TeamA]) != 1 || len(m.Spawns[TeamB]) != 1 {
+		t.Fatalf("spawns = %v", m.Spawns)
+	}
+}
+
+func TestLoadMapRejects(t *testing.T) {
+	enclosed := validRows()
+	enclosed[3] = "......###......."
+	enclosed[4] = "......#.#......."
+	enclosed[5] = "......###......."
+
+	badTile := validRows()
+	badTile[2] = "......x........."
+
+	noSpawn := validRows()
+	noSpawn[MinMapHeight-1] = strings.Repeat(".", MinMapWidth)
+
+	tests := map[string]string{
+		"not json":        `{`,
+		"no name":         `{"width":16,"height":9}`,
+		"negative width":  `{"name":"x","width":-2,"height":1,"tiles":[""]}`,
+		"negative height": `{"name":"x","width":16,"height":-1,"tiles":[]}`,
+		"too large":       mapJSON(MaxMapWidth+1, MinMapHeight, validRows()),
+		"row count":       mapJSON(MinMapWidth, MinMapHeight, validRows()[1:]),
+		"row length":      mapJSON(MinMapWidth+1, MinMapHeight, validRows()),
+		"invalid tile":    mapJSON(MinMapWidth, MinMapHeight, badTile),
+		"no spawn zone":   mapJSON(MinMapWidth, MinMapHeight, noSpawn),
+		"unreachable":     mapJSON(MinMapWidth, MinMapHeight, enclosed),
+	}
+	for name, data := range tests {
+		if _, err := LoadMap([]byte(data)); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestMapPool(t *testing.T) {
+	if len(MapPool) == 0 {
+		t.Fatal("empty map pool")
+	}
+	for _, m := range MapPool {
+		if err := ValidateMap(m); err != nil {
+			t.Errorf("%s: %v", m.Name, err)
+		}
+	}
+}
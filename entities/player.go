@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"errors"
 	"math"
 	"online-game/types"
 )
@@ -11,16 +12,69 @@ const (
 )
 
 type Player struct {
-	User   *User
-	Team   types.TeamID
-	X      float64
-	Y      float64
-	VX     int
-	VY     int
-	Weapon Weapon
+	User    *User
+	Team    types.TeamID
+	X       float64
+	Y       float64
+	VX      int
+	VY      int
+	Loadout Loadout
+	Active  types.Slot // slot of the weapon in hand
+	Ink     float64    // see UseInk
+
+	Input    Input  // directions held as of InputSeq
+	InputSeq uint32 // last input applied
+	AckedSeq uint32 // last state snapshot the client acknowledged
 }
 type Players []*Player
 
+const (
+	PrimarySlot   types.Slot = iota
+	SecondarySlot types.Slot = iota
+)
+
+// Loadout is the weapons a player carries, one per slot
+type Loadout [types.LoadoutSize]Weapon
+
+// Weapon is the weapon in the player's hand
+func (p *Player) Weapon() Weapon {
+	return p.Loadout[p.Active]
+}
+
+// Swap puts the weapon in hand away and takes out the one in the other slot
+func (p *Player) Swap() error {
+	if p.Weapon().GetCharge() > 0 {
+		return errors.New("cannot swap weapons while charging")
+	}
+	p.Active = (p.Active + 1) % types.LoadoutSize
+	return nil
+}
+
+// Input is the set of directions a player holds
+type Input struct {
+	Up    bool
+	Down  bool
+	Left  bool
+	Right bool
+}
+
+// Velocity returns the direction the held keys move in, opposite keys cancel out
+func (i Input) Velocity() (vx, vy int) {
+	if i.Left {
+		vx--
+	}
+	if i.Right {
+		vx++
+	}
+	if i.Up {
+		vy--
+	}
+	if i.Down {
+		vy++
+	}
+	return vx, vy
+}
+
 // TODO: name
 func (p Players) Foo() []types.StateMessagePlayer {
 	smps := make([]types.StateMessagePlayer, len(p))
@@ -41,42 +95,46 @@ func (p *Player) ToStateMessagePlayer() types.StateMessagePlayer {
 			ID:       p.User.ID,
 			Username: p.User.Username,
 		},
-		WeaponId: p.Weapon.Id(),
+		Active:   p.Active,
+		Loadout:  p.Loadout.slots(),
+		Ink:      uint8(math.Round(p.Ink / MaxInk * 100)),
+		InputSeq: p.InputSeq,
 	}
 }
 
-func (p *Player) Move(direction string, start bool) {
-	if start {
-		switch direction {
-		case "up":
-			p.VY += -1
-		case "down":
-			p.VY += 1
-		case "left":
-			p.VX += -1
-		case "right":
-			p.VX += 1
-		}
-	} else {
-		switch direction {
-		case "up":
-			p.VY -= -1
-		case "down":
-			p.VY -= 1
-		case "left":
-			p.VX -= -1
-		case "right":
-			p.VX -= 1
+func (l Loadout) slots() [types.LoadoutSize]types.LoadoutSlot {
+	var slots [types.LoadoutSize]types.LoadoutSlot
+	for i, weapon := range l {
+		slots[i] = types.LoadoutSlot{
+			WeaponId: weapon.Id(),
+			Weapon:   weaponStatus(weapon),
 		}
 	}
-	// Clamp the direction
-	p.VX = int(math.Min(math.Max(float64(p.VX), -1), 1))
-	p.VY = int(math.Min(math.Max(float64(p.VY), -1), 1))
+	return slots
+}
+
+// Move applies the directions held as of input seq. Inputs are complete, so
+// one arriving after a newer one is stale and ignored.
+func (p *Player) Move(seq uint32, input Input) {
+	if seq <= p.InputSeq {
+		return
+	}
+	p.InputSeq = seq
+	p.Input = input
+	p.VX, p.VY = input.Velocity()
 }
 
-func (p *Player) Update(gameMap *types.GameMap) {
-	newX := p.X + float64(p.VX)*PlayerSpeed*float64(GameTick.Seconds())
-	newY := p.Y + float64(p.VY)*PlayerSpeed*float64(GameTick.Seconds())
+// Update moves the player by one tick and refills their ink, then lets their
+// weapons act
+func (p *Player) Update(g *Game) {
+	gameMap := &g.State.GameMap
+	speed := float64(g.Settings.PlayerSpeed)
+	if modifier, ok := p.Weapon().(SpeedModifier); ok {
+		speed *= modifier.SpeedFactor()
+	}
+
+	newX := p.X + float64(p.VX)*speed*float64(GameTick.Seconds())
+	newY := p.Y + float64(p.VY)*speed*float64(GameTick.Seconds())
 
 	tile, bottom, right, bottomRight := GetAround(gameMap, int(math.Floor(newX)), int(math.Floor(newY)))
 	cornerX := newX-math.Floor(newX) > 0
@@ -109,10 +167,23 @@ func (p *Player) Update(gameMap *types.GameMap) {
 
 	p.X = newX
 	p.Y = newY
+	p.refillInk(g)
+
+	for _, weapon := range p.Loadout {
+		if updater, ok := weapon.(WeaponUpdater); ok {
+			updater.Update(&WeaponContext{Game: g, Player: p})
+		}
+	}
 }
 
-// Reset resets the player's velocity
+// Reset resets the player's velocity and held directions
 func (p *Player) Reset() {
 	p.VX = 0
 	p.VY = 0
+	p.Input = Input{}
+	for _, weapon := range p.Loadout {
+		if resetter, ok := weapon.(WeaponResetter); ok {
+			resetter.Reset()
+		}
+	}
 }
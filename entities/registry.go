@@ -0,0 +1,72 @@
+package entities
+
+import "sync"
+
+// Registry indexes the running games by room code and by the IDs of the
+// users playing in them. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	rooms map[string]*Game
+	users map[int16]*Game
+}
+
+// Games holds every running game
+var Games = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		rooms: map[string]*Game{},
+		users: map[int16]*Game{},
+	}
+}
+
+// Add registers a game under its room code
+func (r *Registry) Add(g *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rooms[g.Room] = g
+}
+
+// Remove unregisters a game along with all of its users
+func (r *Registry) Remove(g *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rooms[g.Room] == g {
+		delete(r.rooms, g.Room)
+	}
+	for id, game := range r.users {
+		if game == g {
+			delete(r.users, id)
+		}
+	}
+}
+
+// ByRoom finds a game by its room code
+func (r *Registry) ByRoom(room string) *Game {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rooms[room]
+}
+
+// ByUser finds the game a user is in
+func (r *Registry) ByUser(userId int16) *Game {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.users[userId]
+}
+
+// Bind records that a user is playing in a game
+func (r *Registry) Bind(userId int16, g *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[userId] = g
+}
+
+// Unbind forgets a user's game, if it is still g
+func (r *Registry) Unbind(userId int16, g *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.users[userId] == g {
+		delete(r.users, userId)
+	}
+}
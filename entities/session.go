@@ -0,0 +1,115 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"online-game/msgs"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+// ReconnectGrace is how long a disconnected player keeps their seat
+const ReconnectGrace = 30 * time.Second
+
+var (
+	ErrSessionExpired  = errors.New("session expired")
+	ErrProtocolChanged = errors.New("protocol changed, please reload the page")
+	ErrDisconnected    = errors.New("user is disconnected")
+	ErrOwnSession      = errors.New("session is already attached to this connection")
+)
+
+// sessions maps resume tokens to their users
+var sessions = map[string]*User{}
+var sessionsMu sync.Mutex
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Resume reattaches c, the connection of the current user, to the user the
+// token was issued for. A connection still attached to that user is closed.
+func Resume(token string, current *User, c *websocket.Conn, protocol msgs.Negotiated) (*User, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	u, ok := sessions[token]
+	if !ok {
+		return nil, ErrSessionExpired
+	}
+	if u == current {
+		return nil, ErrOwnSession
+	}
+	if u.Protocol != protocol {
+		return nil, ErrProtocolChanged
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.timer != nil {
+		u.timer.Stop()
+		u.timer = nil
+	}
+	if u.C != nil {
+		u.C.Close()
+	}
+	u.C = c
+	return u, nil
+}
+
+// Disconnect detaches c from the user. A user in a game keeps their seat for
+// ReconnectGrace, anyone else is cleaned up right away.
+func (u *User) Disconnect(c *websocket.Conn) {
+	u.mu.Lock()
+	if u.C != c {
+		// already resumed on another connection
+		u.mu.Unlock()
+		return
+	}
+	u.C = nil
+	u.mu.Unlock()
+
+	game := Games.ByUser(u.ID)
+	if game == nil {
+		u.Cleanup()
+		return
+	}
+
+	game.Do(func() {
+		player := game.GetPlayer(u.ID)
+		if player == nil {
+			return
+		}
+		player.Reset()
+		game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s disconnected", u.Username))
+	})
+
+	u.mu.Lock()
+	if u.C == nil {
+		u.timer = time.AfterFunc(ReconnectGrace, u.expire)
+	}
+	u.mu.Unlock()
+}
+
+// expire cleans up a user that did not resume within ReconnectGrace
+func (u *User) expire() {
+	sessionsMu.Lock()
+	u.mu.Lock()
+	resumed := u.C != nil
+	if !resumed {
+		delete(sessions, u.Token)
+	}
+	u.mu.Unlock()
+	sessionsMu.Unlock()
+
+	if !resumed {
+		u.Cleanup()
+	}
+}
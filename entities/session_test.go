@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"online-game/msgs"
+	"testing"
+)
+
+func TestResumeOwnSession(t *testing.T) {
+	user, err := NewUser(nil, "own", msgs.Negotiated{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer user.Cleanup()
+
+	if _, err := Resume(user.Token, user, nil, user.Protocol); err != ErrOwnSession {
+		t.Fatalf("Resume = %v, want %v", err, ErrOwnSession)
+	}
+}
+
+func TestCleanupTwice(t *testing.T) {
+	user, err := NewUser(nil, "first", msgs.Negotiated{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Cleanup()
+
+	// the freed ID going to another user before the second cleanup
+	UserIDs.mu.Lock()
+	UserIDs.inUse[user.ID] = struct{}{}
+	UserIDs.mu.Unlock()
+	other := &User{ID: user.ID}
+	usersMu.Lock()
+	Users[other.ID] = other
+	usersMu.Unlock()
+	defer other.Cleanup()
+
+	user.Cleanup()
+
+	UserIDs.mu.Lock()
+	_, inUse := UserIDs.inUse[other.ID]
+	UserIDs.mu.Unlock()
+	if !inUse {
+		t.Fatal("second cleanup freed an ID owned by another user")
+	}
+	usersMu.Lock()
+	registered := Users[other.ID] == other
+	usersMu.Unlock()
+	if !registered {
+		t.Fatal("second cleanup removed another user")
+	}
+}
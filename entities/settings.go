@@ -0,0 +1,160 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"online-game/consts"
+	"online-game/msgs"
+	"online-game/types"
+	"time"
+)
+
+// Bounds of the match settings a host can pick
+const (
+	MinDuration     = 30 * time.Second
+	MaxDuration     = 10 * time.Minute
+	MinPlayers      = 2
+	MinPlayerSpeed  = 4
+	MaxPlayerSpeed  = 20
+	MinMapWidth     = 16
+	MaxMapWidth     = 64
+	MinMapHeight    = 9
+	MaxMapHeight    = 36
+	MaxMapDivisions = 10
+)
+
+// MatchSettings are the rules of the matches played in a room
+type MatchSettings struct {
+	Duration     time.Duration
+	MaxPlayers   int
+	PlayerSpeed  int // tiles per second
+	MapWidth     int
+	MapHeight    int
+	MapDivisions int    // depth of the wall partitioning
+	Weapons      uint8  // bit per allowed WeaponId
+	Seed         uint32 // the walls of the map are generated from it
+	Map          int    // index in MapPool plus one, 0 generates the map
+	Symmetry     Symmetry
+	Generator    GeneratorId
+}
+
+// MapGenerator is the generator of the settings' maps, MapDivisions only
+// applies to the BSP one
+func (s MatchSettings) MapGenerator() MapGenerator {
+	switch s.Generator {
+	case CaveGenerator:
+		return Cave{}
+	case MazeGenerator:
+		return Maze{}
+	}
+	return BSP{Divisions: s.MapDivisions}
+}
+
+// PoolMap is the map of the pool the settings play on, nil when the map is
+// generated
+func (s MatchSettings) PoolMap() *Map {
+	if s.Map < 1 || s.Map > len(MapPool) {
+		return nil
+	}
+	return MapPool[s.Map-1]
+}
+
+// Allows reports whether players may pick the weapon
+func (s MatchSettings) Allows(id types.WeaponId) bool {
+	return id < WeaponCount && s.Weapons&(1<<id) != 0
+}
+
+func DefaultMatchSettings() MatchSettings {
+	return MatchSettings{
+		Duration:     60 * time.Second,
+		MaxPlayers:   MaxPlayers,
+		PlayerSpeed:  10,
+		MapWidth:     48,
+		MapHeight:    27,
+		MapDivisions: consts.MAP_DIVISIONS,
+		Weapons:      AllWeapons,
+		Seed:         rand.Uint32(),
+	}
+}
+
+// SettingsFromMessage reads the settings a host asked for, a map of the pool
+// sets the size of the map
+func SettingsFromMessage(cm msgs.ConfigureMessage) MatchSettings {
+	s := MatchSettings{
+		Duration:     time.Duration(cm.Duration) * time.Second,
+		MaxPlayers:   int(cm.MaxPlayers),
+		PlayerSpeed:  int(cm.PlayerSpeed),
+		MapWidth:     int(cm.MapWidth),
+		MapHeight:    int(cm.MapHeight),
+		MapDivisions: int(cm.MapDivisions),
+		Weapons:      cm.Weapons,
+		Seed:         cm.Seed,
+		Map:          int(cm.Map),
+		Symmetry:     Symmetry(cm.Symmetry),
+		Generator:    GeneratorId(cm.Generator),
+	}
+	if m := s.PoolMap(); m != nil {
+		s.MapWidth = m.GameMap.Width
+		s.MapHeight = m.GameMap.Height
+	}
+	return s
+}
+
+func (s MatchSettings) Message() msgs.ConfiguredMessage {
+	return msgs.ConfiguredMessage{
+		Duration:     uint16(s.Duration / time.Second),
+		MaxPlayers:   uint8(s.MaxPlayers),
+		PlayerSpeed:  uint8(s.PlayerSpeed),
+		MapWidth:     uint8(s.MapWidth),
+		MapHeight:    uint8(s.MapHeight),
+		MapDivisions: uint8(s.MapDivisions),
+		Weapons:      s.Weapons,
+		Seed:         s.Seed,
+		Map:          uint8(s.Map),
+		Symmetry:     uint8(s.Symmetry),
+		Generator:    uint8(s.Generator),
+	}
+}
+
+// Validate checks the settings are within the bounds above
+func (s MatchSettings) Validate() error {
+	if s.Duration < MinDuration || s.Duration > MaxDuration || s.Duration%time.Second != 0 {
+		return fmt.Errorf("duration must be between %d and %d seconds", MinDuration/time.Second, MaxDuration/time.Second)
+	}
+	if s.MaxPlayers < MinPlayers || s.MaxPlayers > MaxPlayers {
+		return fmt.Errorf("max players must be between %d and %d", MinPlayers, MaxPlayers)
+	}
+	if s.PlayerSpeed < MinPlayerSpeed || s.PlayerSpeed > MaxPlayerSpeed {
+		return fmt.Errorf("player speed must be between %d and %d", MinPlayerSpeed, MaxPlayerSpeed)
+	}
+	if s.MapWidth < MinMapWidth || s.MapWidth > MaxMapWidth {
+		return fmt.Errorf("map width must be between %d and %d", MinMapWidth, MaxMapWidth)
+	}
+	if s.MapHeight < MinMapHeight || s.MapHeight > MaxMapHeight {
+		return fmt.Errorf("map height must be between %d and %d", MinMapHeight, MaxMapHeight)
+	}
+	if s.MapDivisions < 0 || s.MapDivisions > MaxMapDivisions {
+		return fmt.Errorf("map divisions must be between 0 and %d", MaxMapDivisions)
+	}
+	if s.Map < 0 || s.Map > len(MapPool) {
+		return fmt.Errorf("map must be between 0 and %d", len(MapPool))
+	}
+	if s.Symmetry >= SymmetryCount {
+		return errors.New("unknown map symmetry")
+	}
+	if s.Generator >= GeneratorCount {
+		return errors.New("unknown map generator")
+	}
+	if s.Weapons == 0 || s.Weapons&^AllWeapons != 0 {
+		return errors.New("at least one weapon must be allowed, and only known ones")
+	}
+	return nil
+}
+
+// sameMap reports whether both settings generate the same kind of map
+func (s MatchSettings) sameMap(o MatchSettings) bool {
+	return s.MapWidth == o.MapWidth && s.MapHeight == o.MapHeight && s.MapDivisions == o.MapDivisions &&
+		s.Seed == o.Seed && s.Map == o.Map && s.Symmetry == o.Symmetry &&
+		s.Generator == o.Generator
+}
@@ -1,6 +1,8 @@
 package entities
 
 import (
+	"hash/fnv"
+	"math"
 	"math/rand"
 	"online-game/consts"
 	"online-game/types"
@@ -19,11 +21,29 @@ const (
 	GameOver          types.GamePhase = iota
 )
 
-func RandMN(m int, n int) int {
-	return m + rand.Intn(n-m)
+func RandMN(r *rand.Rand, m int, n int) int {
+	return m + r.Intn(n-m)
 }
 
-func NewGameState(width, height int) *types.GameState {
+// NewGameState sets up the map of the settings, either a map of the pool or
+// one generated from the seed. The same settings always give the same map.
+func NewGameState(settings MatchSettings) *types.GameState {
+	state := &types.GameState{
+		TeamA: consts.TEAM_A_COLOR,
+		TeamB: consts.TEAM_B_COLOR,
+		Phase: WaitingForPlayers,
+	}
+
+	if m := settings.PoolMap(); m != nil {
+		state.GameMap = types.GameMap{
+			Width:  m.GameMap.Width,
+			Height: m.GameMap.Height,
+			Tiles:  append([]types.Tile(nil), m.GameMap.Tiles...),
+		}
+		return state
+	}
+
+	width, height := settings.MapWidth, settings.MapHeight
 	gameMap := types.GameMap{
 		Width:  width,
 		Height: height,
@@ -36,18 +56,16 @@ func NewGameState(width, height int) *types.GameState {
 	}
 
 	// walls
-	generateWalls(&gameMap, consts.MAP_DIVISIONS)
+	r := rand.New(rand.NewSource(int64(settings.Seed)))
+	generateWalls(&gameMap, settings.MapGenerator(), settings.Symmetry, r)
+	connect(&gameMap, settings.Symmetry, r)
 
-	return &types.GameState{
-		GameMap: gameMap,
-		TeamA:   consts.TEAM_A_COLOR,
-		TeamB:   consts.TEAM_B_COLOR,
-		Phase:   WaitingForPlayers,
-	}
+	state.GameMap = gameMap
+	return state
 }
 
-func RandomGameState(width, height int) *types.GameState {
-	gameState := NewGameState(width, height)
+func RandomGameState(settings MatchSettings) *types.GameState {
+	gameState := NewGameState(settings)
 
 	// Fill the map with random team tiles
 	for i, tile := range gameState.GameMap.Tiles {
@@ -84,7 +102,100 @@ func Set(m *types.GameMap, x, y int, tile types.Tile) {
 		return
 	}
 
-	m.Tiles[y*m.Width+x] = tile
+	i := y*m.Width + x
+	if m.Journal != nil && m.Tiles[i] != tile {
+		m.Journal.Changed = append(m.Journal.Changed, i)
+	}
+	m.Tiles[i] = tile
+}
+
+// Cell is the position of a tile on a GameMap
+type Cell struct {
+	X int
+	Y int
+}
+
+// Raycast walks the tiles a ray from (x, y) along the unit vector (dx, dy)
+// crosses, for up to length tiles. It returns the tiles crossed before the
+// first wall, and the point where the ray stopped.
+func Raycast(m *types.GameMap, x, y, dx, dy, length float64) ([]Cell, float64, float64) {
+	cx, cy := int(math.Floor(x)), int(math.Floor(y))
+	if Get(m, cx, cy) == WallTile {
+		return nil, x, y
+	}
+
+	// distance along the ray to the next column and row boundaries, and
+	// between two of them
+	stepX, tMaxX, tDeltaX := rayAxis(x, dx)
+	stepY, tMaxY, tDeltaY := rayAxis(y, dy)
+
+	cells := []Cell{{cx, cy}}
+	t := 0.0
+	for {
+		if tMaxX < tMaxY {
+			t = tMaxX
+			tMaxX += tDeltaX
+			cx += stepX
+		} else {
+			t = tMaxY
+			tMaxY += tDeltaY
+			cy += stepY
+		}
+		if t >= length {
+			t = length
+			break
+		}
+		if Get(m, cx, cy) == WallTile {
+			break
+		}
+		cells = append(cells, Cell{cx, cy})
+	}
+
+	return cells, x + dx*t, y + dy*t
+}
+
+// Reachable walks from start to every tile that is not a wall and can be
+// reached without crossing one, moving up, down, left or right
+func Reachable(m *types.GameMap, start Cell) []Cell {
+	if Get(m, start.X, start.Y) == WallTile {
+		return nil
+	}
+
+	seen := make([]bool, len(m.Tiles))
+	seen[start.Y*m.Width+start.X] = true
+	cells := []Cell{start}
+	for i := 0; i < len(cells); i++ {
+		cell := cells[i]
+		for _, next := range []Cell{{cell.X + 1, cell.Y}, {cell.X - 1, cell.Y}, {cell.X, cell.Y + 1}, {cell.X, cell.Y - 1}} {
+			if Get(m, next.X, next.Y) == WallTile || seen[next.Y*m.Width+next.X] {
+				continue
+			}
+			seen[next.Y*m.Width+next.X] = true
+			cells = append(cells, next)
+		}
+	}
+	return cells
+}
+
+func rayAxis(p, d float64) (step int, tMax, tDelta float64) {
+	switch {
+	case d > 0:
+		return 1, (math.Floor(p) + 1 - p) / d, 1 / d
+	case d < 0:
+		return -1, (p - math.Floor(p)) / -d, 1 / -d
+	}
+	return 0, math.Inf(1), math.Inf(1)
+}
+
+// Checksum hashes the map's tiles so clients can detect that they drifted
+func Checksum(m *types.GameMap) uint32 {
+	h := fnv.New32a()
+	buf := make([]byte, len(m.Tiles))
+	for i, tile := range m.Tiles {
+		buf[i] = byte(tile)
+	}
+	h.Write(buf)
+	return h.Sum32()
 }
 
 func Clear(m *types.GameMap) {
@@ -95,11 +206,21 @@ func Clear(m *types.GameMap) {
 	}
 }
 
-func generateWalls(m *types.GameMap, divisions int) {
-	generateWallsInRange(m, 0, 0, m.Width-1, m.Height-1, divisions)
+// generateWalls generates the walls of one half of the map and mirrors them
+// onto the other, or of the whole map without symmetry
+func generateWalls(m *types.GameMap, gen MapGenerator, sym Symmetry, r *rand.Rand) {
+	x1, y1, x2, y2 := sym.half(m)
+	gen.Generate(m, x1, y1, x2, y2, r)
+
+	for y := y1; y <= y2; y++ {
+		for x := x1; x <= x2; x++ {
+			c := sym.mirror(m, Cell{x, y})
+			Set(m, c.X, c.Y, Get(m, x, y))
+		}
+	}
 }
 
-func generateWallsInRange(m *types.GameMap, x1, y1, x2, y2 int, divisions int) {
+func generateWallsInRange(m *types.GameMap, x1, y1, x2, y2 int, divisions int, r *rand.Rand) {
 	// use BSP to generate walls
 	if divisions == 0 {
 		return
@@ -112,9 +233,9 @@ func generateWallsInRange(m *types.GameMap, x1, y1, x2, y2 int, divisions int) {
 		return
 	}
 
-	dir := RandMN(0, 2) // 0: horizontal, 1: vertical
-	px := RandMN(x1+consts.ROOM_PADDING, x2-consts.ROOM_PADDING)
-	py := RandMN(y1+consts.ROOM_PADDING, y2-consts.ROOM_PADDING)
+	dir := RandMN(r, 0, 2) // 0: horizontal, 1: vertical
+	px := RandMN(r, x1+consts.ROOM_PADDING, x2-consts.ROOM_PADDING)
+	py := RandMN(r, y1+consts.ROOM_PADDING, y2-consts.ROOM_PADDING)
 
 	if dir == 0 { // horizontal
 		// draw a horizontal line
@@ -122,15 +243,130 @@ func generateWallsInRange(m *types.GameMap, x1, y1, x2, y2 int, divisions int) {
 			Set(m, x, py, WallTile)
 		}
 		// divide the map into two parts
-		generateWallsInRange(m, x1, y1, x2, py-1, divisions-1)
-		generateWallsInRange(m, x1, py+1, x2, y2, divisions-1)
+		generateWallsInRange(m, x1, y1, x2, py-1, divisions-1, r)
+		generateWallsInRange(m, x1, py+1, x2, y2, divisions-1, r)
 	} else { // vertical
 		// draw a vertical line
 		for y := y1 + consts.ROOM_PADDING; y <= y2-consts.ROOM_PADDING; y++ {
 			Set(m, px, y, WallTile)
 		}
 		// divide the map into two parts
-		generateWallsInRange(m, x1, y1, px-1, y2, divisions-1)
-		generateWallsInRange(m, px+1, y1, x2, y2, divisions-1)
+		generateWallsInRange(m, x1, y1, px-1, y2, divisions-1, r)
+		generateWallsInRange(m, px+1, y1, x2, y2, divisions-1, r)
+	}
+}
+
+// connect punches doorways through walls until every tile that is not a wall
+// can be reached from every other, each with its mirror to keep the map
+// symmetric. Regions more than one wall tile away from the others are joined
+// by the shortest tunnel instead.
+func connect(m *types.GameMap, sym Symmetry, r *rand.Rand) {
+	for {
+		regions, count := Regions(m)
+		if count <= 1 {
+			return
+		}
+
+		// walls with the first region on one side and another on the
+		// opposite side
+		var doors []Cell
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				if Get(m, x, y) != WallTile {
+					continue
+				}
+				if joins(m, regions, Cell{x - 1, y}, Cell{x + 1, y}) || joins(m, regions, Cell{x, y - 1}, Cell{x, y + 1}) {
+					doors = append(doors, Cell{x, y})
+				}
+			}
+		}
+		if len(doors) == 0 {
+			doors = tunnel(m, regions)
+		} else {
+			doors = doors[r.Intn(len(doors)):][:1]
+		}
+
+		for _, door := range doors {
+			mirror := sym.mirror(m, door)
+			Set(m, door.X, door.Y, EmptyTile)
+			Set(m, mirror.X, mirror.Y, EmptyTile)
+		}
+	}
+}
+
+// tunnel is the shortest run of walls from the first region to another
+func tunnel(m *types.GameMap, regions []int) []Cell {
+	// walk out of the first region through walls only, remembering where
+	// every wall was reached from
+	from := make([]int, len(m.Tiles))
+	for i := range from {
+		from[i] = -1
+	}
+	var queue []int
+	for i, region := range regions {
+		if region == 0 {
+			from[i] = i
+			queue = append(queue, i)
+		}
+	}
+
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		x, y := i%m.Width, i/m.Width
+		for _, n := range []Cell{{x + 1, y}, {x - 1, y}, {x, y + 1}, {x, y - 1}} {
+			if n.X < 0 || n.X >= m.Width || n.Y < 0 || n.Y >= m.Height {
+				continue
+			}
+			j := n.Y*m.Width + n.X
+			if from[j] != -1 {
+				continue
+			}
+			from[j] = i
+			if regions[j] <= 0 {
+				queue = append(queue, j)
+				continue
+			}
+
+			// reached another region, back to the first one
+			var cells []Cell
+			for k := i; regions[k] == -1; k = from[k] {
+				cells = append(cells, Cell{k % m.Width, k / m.Width})
+			}
+			return cells
+		}
+	}
+	return nil
+}
+
+// joins reports whether a and b are in different regions, one of them the
+// first
+func joins(m *types.GameMap, regions []int, a, b Cell) bool {
+	if Get(m, a.X, a.Y) == WallTile || Get(m, b.X, b.Y) == WallTile {
+		return false
+	}
+	ra, rb := regions[a.Y*m.Width+a.X], regions[b.Y*m.Width+b.X]
+	return ra != rb && (ra == 0 || rb == 0)
+}
+
+// Regions numbers the regions of the map, the tiles that can be reached from
+// each other. It returns the region of every tile, -1 for walls, and how many
+// regions there are.
+func Regions(m *types.GameMap) ([]int, int) {
+	regions := make([]int, len(m.Tiles))
+	for i := range regions {
+		regions[i] = -1
+	}
+
+	count := 0
+	for i, tile := range m.Tiles {
+		if tile == WallTile || regions[i] != -1 {
+			continue
+		}
+		for _, cell := range Reachable(m, Cell{i % m.Width, i / m.Width}) {
+			regions[cell.Y*m.Width+cell.X] = count
+		}
+		count++
 	}
+	return regions, count
 }
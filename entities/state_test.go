@@ -0,0 +1,250 @@
+package entities
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"online-game/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden maps in testdata")
+
+var generatorNames = map[GeneratorId]string{
+	BSPGenerator:  "bsp",
+	CaveGenerator: "cave",
+	MazeGenerator: "maze",
+}
+
+var symmetryNames = map[Symmetry]string{
+	NoSymmetry:         "none",
+	HorizontalSymmetry: "horizontal",
+	VerticalSymmetry:   "vertical",
+	RotationalSymmetry: "rotational",
+}
+
+// layout draws the map a row per line, with the characters of map files
+func layout(settings MatchSettings) string {
+	m := &NewGameState(settings).GameMap
+	b := &strings.Builder{}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if Get(m, x, y) == WallTile {
+				b.WriteByte(wallRune)
+			} else {
+				b.WriteByte(emptyRune)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// TestGoldenMaps checks fixed seeds still generate the maps they generated
+// when the layouts in testdata were recorded, so a seed from a bug report or
+// a tournament gives the same map. Run with -update after changing a
+// generator on purpose.
+func TestGoldenMaps(t *testing.T) {
+	for gen := GeneratorId(0); gen < GeneratorCount; gen++ {
+		for sym := Symmetry(0); sym < SymmetryCount; sym++ {
+			settings := DefaultMatchSettings()
+			settings.MapWidth = 32
+			settings.MapHeight = 18
+			settings.MapDivisions = 4
+			settings.Seed = 20241018 + uint32(gen)*10 + uint32(sym)
+			settings.Generator = gen
+			settings.Symmetry = sym
+
+			name := fmt.Sprintf("%s-%s", generatorNames[gen], symmetryNames[sym])
+			t.Run(name, func(t *testing.T) {
+				got := layout(settings)
+				if got != layout(settings) {
+					t.Fatal("the same settings generated two maps")
+				}
+
+				path := filepath.Join("testdata", "maps", name+".txt")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Fatalf("seed %d generated\n%s\nwant\n%s", settings.Seed, got, want)
+				}
+			})
+		}
+	}
+}
+
+// mapSizes are the sizes the property tests generate, the bounds of the
+// settings and an odd size between them
+var mapSizes = [][2]int{
+	{MinMapWidth, MinMapHeight},
+	{MinMapWidth + 7, MinMapHeight + 4},
+	{MaxMapWidth, MaxMapHeight},
+}
+
+// TestMapsConnected checks every open tile of a generated map can be reached
+// from every other, over many seeds
+func TestMapsConnected(t *testing.T) {
+	seeds := uint32(1000)
+	if testing.Short() {
+		seeds = 100
+	}
+	for gen := GeneratorId(0); gen < GeneratorCount; gen++ {
+		for _, size := range mapSizes {
+			for sym := Symmetry(0); sym < SymmetryCount; sym++ {
+				settings := DefaultMatchSettings()
+				settings.MapWidth, settings.MapHeight = size[0], size[1]
+				settings.Generator = gen
+				settings.Symmetry = sym
+				for seed := uint32(0); seed < seeds; seed++ {
+					settings.Seed = seed
+					settings.MapDivisions = int(seed % (MaxMapDivisions + 1))
+					m := NewGameState(settings).GameMap
+					if _, n := Regions(&m); n != 1 {
+						t.Fatalf("%s %dx%d %s seed %d divisions %d: %d regions", generatorNames[gen], size[0], size[1], symmetryNames[sym], seed, settings.MapDivisions, n)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestSmallMapsOpen checks the cave and maze generators leave room to play
+// on the smallest map, where a cave closes easily and the maze's corridors
+// barely fit in the half that gets mirrored
+func TestSmallMapsOpen(t *testing.T) {
+	for _, gen := range []GeneratorId{CaveGenerator, MazeGenerator} {
+		for sym := Symmetry(0); sym < SymmetryCount; sym++ {
+			settings := DefaultMatchSettings()
+			settings.MapWidth, settings.MapHeight = MinMapWidth, MinMapHeight
+			settings.Generator = gen
+			settings.Symmetry = sym
+			for seed := uint32(0); seed < 200; seed++ {
+				settings.Seed = seed
+				state := NewGameState(settings)
+				open := 0
+				for _, tile := range state.GameMap.Tiles {
+					if tile != WallTile {
+						open++
+					}
+				}
+				if open < len(state.GameMap.Tiles)/4 {
+					t.Fatalf("%s %s seed %d: %d of %d tiles open", generatorNames[gen], symmetryNames[sym], seed, open, len(state.GameMap.Tiles))
+				}
+				spawns := SpawnZones(&state.GameMap, sym)
+				if sym != NoSymmetry && (len(spawns[TeamA]) == 0 || len(spawns[TeamB]) == 0) {
+					t.Fatalf("%s %s seed %d: no spawn zone", generatorNames[gen], symmetryNames[sym], seed)
+				}
+			}
+		}
+	}
+}
+
+// testMap builds a map from rows of the characters of map files
+func testMap(rows ...string) *types.GameMap {
+	m := &types.GameMap{Width: len(rows[0]), Height: len(rows)}
+	m.Tiles = make([]types.Tile, m.Width*m.Height)
+	for y, row := range rows {
+		for x := range row {
+			if row[x] == wallRune {
+				m.Tiles[y*m.Width+x] = WallTile
+			} else {
+				m.Tiles[y*m.Width+x] = EmptyTile
+			}
+		}
+	}
+	return m
+}
+
+func TestRaycast(t *testing.T) {
+	m := testMap(
+		"....#",
+		".#...",
+		".....",
+		".....",
+	)
+	diagonal := 1 / math.Sqrt2
+	tests := []struct {
+		name         string
+		x, y, dx, dy float64
+		length       float64
+		cells        []Cell
+		endX, endY   float64
+	}{
+		{
+			name: "inside a wall",
+			x:    1.5, y: 1.5, dx: 1, length: 10,
+			endX: 1.5, endY: 1.5,
+		},
+		{
+			name: "right to a wall",
+			x:    0.5, y: 0.5, dx: 1, length: 10,
+			cells: []Cell{{0, 0}, {1, 0}, {2, 0}, {3, 0}},
+			endX:  4, endY: 0.5,
+		},
+		{
+			name: "left from a tile boundary",
+			x:    3, y: 2.5, dx: -1, length: 10,
+			cells: []Cell{{3, 2}, {2, 2}, {1, 2}, {0, 2}},
+			endX:  0, endY: 2.5,
+		},
+		{
+			name: "up from a tile boundary",
+			x:    2.5, y: 3, dy: -1, length: 10,
+			cells: []Cell{{2, 3}, {2, 2}, {2, 1}, {2, 0}},
+			endX:  2.5, endY: 0,
+		},
+		{
+			name: "down to the edge",
+			x:    0.5, y: 0.5, dy: 1, length: 10,
+			cells: []Cell{{0, 0}, {0, 1}, {0, 2}, {0, 3}},
+			endX:  0.5, endY: 4,
+		},
+		{
+			name: "stops at length",
+			x:    0.5, y: 2.5, dx: 1, length: 2,
+			cells: []Cell{{0, 2}, {1, 2}, {2, 2}},
+			endX:  2.5, endY: 2.5,
+		},
+		{
+			// through a corner the ray crosses the row before the column
+			name: "diagonal through corners",
+			x:    2.5, y: 1.5, dx: diagonal, dy: diagonal, length: 2 * math.Sqrt2,
+			cells: []Cell{{2, 1}, {2, 2}, {3, 2}, {3, 3}, {4, 3}},
+			endX:  4.5, endY: 3.5,
+		},
+		{
+			name: "diagonal stopped at a corner",
+			x:    0.5, y: 0.5, dx: diagonal, dy: diagonal, length: 10,
+			cells: []Cell{{0, 0}, {0, 1}},
+			endX:  1, endY: 1,
+		},
+	}
+
+	const epsilon = 1e-9
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cells, endX, endY := Raycast(m, test.x, test.y, test.dx, test.dy, test.length)
+			if fmt.Sprint(cells) != fmt.Sprint(test.cells) {
+				t.Errorf("cells = %v, want %v", cells, test.cells)
+			}
+			if math.Abs(endX-test.endX) > epsilon || math.Abs(endY-test.endY) > epsilon {
+				t.Errorf("end = (%v, %v), want (%v, %v)", endX, endY, test.endX, test.endY)
+			}
+		})
+	}
+}
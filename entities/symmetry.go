@@ -0,0 +1,72 @@
+package entities
+
+import "online-game/types"
+
+// Symmetry is how a generated map repeats one half onto the other, so neither
+// team gets the better side
+type Symmetry uint8
+
+const (
+	NoSymmetry         Symmetry = iota
+	HorizontalSymmetry Symmetry = iota // the left half mirrored onto the right
+	VerticalSymmetry   Symmetry = iota // the top half mirrored onto the bottom
+	RotationalSymmetry Symmetry = iota // the left half turned half a turn onto the right
+	SymmetryCount      Symmetry = iota
+)
+
+// mirror is the tile matching c on the other half, c itself without symmetry
+func (s Symmetry) mirror(m *types.GameMap, c Cell) Cell {
+	switch s {
+	case HorizontalSymmetry:
+		return Cell{m.Width - 1 - c.X, c.Y}
+	case VerticalSymmetry:
+		return Cell{c.X, m.Height - 1 - c.Y}
+	case RotationalSymmetry:
+		return Cell{m.Width - 1 - c.X, m.Height - 1 - c.Y}
+	}
+	return c
+}
+
+// half is the range of tiles the walls are generated in before being
+// mirrored, the middle row or column included
+func (s Symmetry) half(m *types.GameMap) (x1, y1, x2, y2 int) {
+	switch s {
+	case HorizontalSymmetry, RotationalSymmetry:
+		return 0, 0, (m.Width - 1) / 2, m.Height - 1
+	case VerticalSymmetry:
+		return 0, 0, m.Width - 1, (m.Height - 1) / 2
+	}
+	return 0, 0, m.Width - 1, m.Height - 1
+}
+
+// SpawnZones are the tiles each team spawns on in a symmetric map: team A on
+// the open tiles along the outer edge of the first half, a quarter of the
+// map deep or more until there are some, team B on their mirrors. Both
+// zones are empty without symmetry.
+func SpawnZones(m *types.GameMap, s Symmetry) [2][]Cell {
+	var zones [2][]Cell
+	if s == NoSymmetry {
+		return zones
+	}
+
+	// lines of tiles from the edge of the first half inwards, columns or
+	// rows for vertical symmetry
+	lines, length, depth := m.Width, m.Height, m.Width/4
+	if s == VerticalSymmetry {
+		lines, length, depth = m.Height, m.Width, m.Height/4
+	}
+	for line := 0; line <= (lines-1)/2 && (line <= depth || len(zones[TeamA]) == 0); line++ {
+		for i := 0; i < length; i++ {
+			c := Cell{line, i}
+			if s == VerticalSymmetry {
+				c = Cell{i, line}
+			}
+			if Get(m, c.X, c.Y) == WallTile {
+				continue
+			}
+			zones[TeamA] = append(zones[TeamA], c)
+			zones[TeamB] = append(zones[TeamB], s.mirror(m, c))
+		}
+	}
+	return zones
+}
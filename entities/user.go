@@ -1,9 +1,12 @@
 package entities
 
 import (
+	"encoding/json"
+	"fmt"
 	"online-game/msgs"
 	"online-game/types"
 	"sync"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 )
@@ -12,26 +15,50 @@ import (
 type User struct {
 	ID       int16
 	Username string
-	C        *websocket.Conn
+	Protocol msgs.Negotiated
+	Token    string          // resumes the session after a disconnect
+	C        *websocket.Conn // nil while disconnected
 	mu       sync.Mutex
+	timer    *time.Timer // expires the seat of a disconnected user
+	cleaned  bool        // set once Cleanup ran, the ID may belong to someone else
 }
 
 var Users = map[int16]*User{}
+var usersMu sync.Mutex
 
-func NewUser(c *websocket.Conn, id int16, username string) *User {
+// NewUser allocates an ID for a new connection and registers the user
+func NewUser(c *websocket.Conn, username string, protocol msgs.Negotiated) (*User, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	id, err := UserIDs.Allocate()
+	if err != nil {
+		return nil, err
+	}
 	user := &User{
 		ID:       id,
 		Username: username,
+		Protocol: protocol,
+		Token:    token,
 		C:        c,
 	}
+	usersMu.Lock()
 	Users[id] = user
-	return user
+	usersMu.Unlock()
+	sessionsMu.Lock()
+	sessions[token] = user
+	sessionsMu.Unlock()
+	return user, nil
 }
 
 // Send sends a message to the user
 func (u *User) Send(msg []byte) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
+	if u.C == nil {
+		return ErrDisconnected
+	}
 	return u.C.WriteMessage(websocket.BinaryMessage, msg)
 }
 
@@ -40,7 +67,31 @@ func (u *User) SendMessage(msg msgs.ServerMessage) error {
 	if !ok {
 		return nil
 	}
-	return u.C.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+	u.Debug(msg)
+	return u.Send(buf.Bytes())
+}
+
+// Debug sends the JSON form of a message as a text frame, if the user
+// negotiated FEATURE_JSON_DEBUG
+func (u *User) Debug(msg msgs.ServerMessage) {
+	if !u.Protocol.Has(msgs.FEATURE_JSON_DEBUG) {
+		return
+	}
+
+	js, err := json.Marshal(map[string]interface{}{
+		"type": fmt.Sprintf("%T", msg),
+		"data": msg,
+	})
+	if err != nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.C == nil {
+		return
+	}
+	u.C.WriteMessage(websocket.TextMessage, js)
 }
 
 // Error sends an error message to the user
@@ -49,19 +100,37 @@ func (u *User) Error(message string) {
 	u.SendMessage(em)
 }
 
-func (u *User) ToPlayer(team types.TeamID, weapon *Weapon) *Player {
+func (u *User) ToPlayer(team types.TeamID, loadout Loadout) *Player {
 	return &Player{
-		User:   u,
-		Team:   team,
-		Weapon: *weapon,
+		User:    u,
+		Team:    team,
+		Loadout: loadout,
+		Ink:     MaxInk,
 	}
 }
 
-// Cleanup removes the user from the game and global map
+// Cleanup removes the user from the game, the global map and the sessions,
+// and frees their ID. Only the first call does anything.
 func (u *User) Cleanup() {
-	game := FindUserInfo(u.ID)
+	u.mu.Lock()
+	cleaned := u.cleaned
+	u.cleaned = true
+	u.mu.Unlock()
+	if cleaned {
+		return
+	}
+
+	game := Games.ByUser(u.ID)
 	if game != nil {
-		game.RemovePlayer(u.ID)
+		game.Do(func() {
+			game.RemovePlayer(u.ID)
+		})
 	}
+	usersMu.Lock()
 	delete(Users, u.ID)
+	usersMu.Unlock()
+	sessionsMu.Lock()
+	delete(sessions, u.Token)
+	sessionsMu.Unlock()
+	UserIDs.Free(u.ID)
 }
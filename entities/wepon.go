@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"math"
 	"online-game/msgs"
 	"online-game/types"
 )
@@ -9,16 +10,93 @@ type Weapon interface {
 	Id() types.WeaponId
 	Name() string
 	GetCooldown() int
+	// GetCooldownLeft is the seconds left until the weapon can fire again
 	GetCooldownLeft() float64
-	OnWeaponDown(game *Game, player *Player, data map[string]interface{}) (map[string]interface{}, error)
-	OnWeaponUpdate(game *Game, player *Player, data map[string]interface{}) (map[string]interface{}, error)
-	OnWeaponUp(game *Game, player *Player, data map[string]interface{}) (map[string]interface{}, error)
-	ParseWeaponDownMessage(message msgs.GenericMessage) (map[string]interface{}, bool)
-	ParseWeaponUpMessage(message msgs.GenericMessage) (map[string]interface{}, bool)
-	ParseWeaponUpdateMessage(message msgs.GenericMessage) (map[string]interface{}, bool)
+	// GetCharge is how far the weapon charged, from 0 to 1
+	GetCharge() float64
+	// GetAmmo is the ink or ammo the weapon has left, from 0 to 1
+	GetAmmo() float64
+	// Parse*Message decode the arguments of a weapon message, after the weapon id
+	ParseWeaponDownMessage(args []byte) (WeaponEvent, bool)
+	ParseWeaponUpdateMessage(args []byte) (WeaponEvent, bool)
+	ParseWeaponUpMessage(args []byte) (WeaponEvent, bool)
 	Stringify() map[string]interface{}
 }
 
+// WeaponUpdater is implemented by weapons that act on every tick of a match,
+// see Player.Update
+type WeaponUpdater interface {
+	Update(ctx *WeaponContext)
+}
+
+// SpeedModifier is implemented by weapons that change how fast their player
+// moves
+type SpeedModifier interface {
+	SpeedFactor() float64
+}
+
+// WeaponResetter is implemented by weapons with state to drop when their
+// player is reset, see Player.Reset
+type WeaponResetter interface {
+	Reset()
+}
+
+// WeaponEvent is a parsed weapon message. Every weapon has its own payload
+// types, which hand themselves to the weapon's matching hook.
+type WeaponEvent interface {
+	Apply(ctx *WeaponContext) error
+}
+
+// WeaponContext is what a weapon hook gets to act on the game with. It is only
+// valid on the game's goroutine.
+type WeaponContext struct {
+	Game   *Game
+	Player *Player
+}
+
+// Broadcast sends a message to every player, the weapon's owner included
+func (ctx *WeaponContext) Broadcast(message msgs.ServerMessage) {
+	ctx.Game.Broadcast(message)
+}
+
+// Notify sends a message to every player but the weapon's owner
+func (ctx *WeaponContext) Notify(message msgs.ServerMessage) {
+	ctx.Game.Broadcast(message, ctx.Player.User.ID)
+}
+
+// ParseWeaponEvent decodes a weapon message meant for the weapon in the
+// player's hand. The message addresses the weapon by slot, so that one sent
+// before a swap is not taken by the other weapon.
+func ParseWeaponEvent(player *Player, message msgs.GenericMessage) (WeaponEvent, bool) {
+	if len(message.Args) < 1 || types.Slot(message.Args[0]) != player.Active {
+		return nil, false
+	}
+	weapon := player.Weapon()
+	args, ok := CheckWeaponId(weapon.Id(), message.Args[1:])
+	if !ok {
+		return nil, false
+	}
+
+	switch message.Type {
+	case msgs.MSG_WEAPONDOWN:
+		return weapon.ParseWeaponDownMessage(args)
+	case msgs.MSG_WEAPONUPDATE:
+		return weapon.ParseWeaponUpdateMessage(args)
+	case msgs.MSG_WEAPONUP:
+		return weapon.ParseWeaponUpMessage(args)
+	}
+	return nil, false
+}
+
+// weaponStatus quantizes the weapon's state for the state messages
+func weaponStatus(w Weapon) types.WeaponStatus {
+	return types.WeaponStatus{
+		Cooldown: uint16(math.Min(math.Max(w.GetCooldownLeft()*1000, 0), math.MaxUint16)),
+		Charge:   uint8(math.Round(math.Min(math.Max(w.GetCharge(), 0), 1) * math.MaxUint8)),
+		Ammo:     uint8(math.Round(math.Min(math.Max(w.GetAmmo(), 0), 1) * math.MaxUint8)),
+	}
+}
+
 func CheckWeaponId(id types.WeaponId, buf []byte) ([]byte, bool) {
 	if len(buf) < 1 {
 		return buf, false
@@ -28,4 +106,12 @@ func CheckWeaponId(id types.WeaponId, buf []byte) ([]byte, bool) {
 
 const (
 	GrenadeId types.WeaponId = iota
+	RollerId  types.WeaponId = iota
+	ChargerId types.WeaponId = iota
+	MineId    types.WeaponId = iota
+
+	WeaponCount = iota // number of weapon ids
 )
+
+// AllWeapons allows every weapon
+const AllWeapons uint8 = 1<<WeaponCount - 1
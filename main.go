@@ -1,16 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
 	"online-game/entities"
 	"online-game/msgs"
-	"online-game/types"
 	"online-game/wepons"
 	"strings"
-	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
@@ -25,45 +24,6 @@ func randomName() string {
 	return fmt.Sprintf("%s %s %s", first[rand.Intn(len(first))], second[rand.Intn(len(second))], third[rand.Intn(len(third))])
 }
 
-func UpdateState() {
-	for _, game := range entities.Games {
-		game.Update()
-	}
-}
-
-func BroadcastState() {
-	for _, game := range entities.Games {
-		if game.State.Phase != entities.Playing && !game.LC {
-			continue
-		}
-		game.BroadcastState()
-		game.LC = false
-	}
-}
-
-func BroadcastMap() {
-	for _, game := range entities.Games {
-		if game.State.Phase != entities.Playing {
-			continue
-		}
-
-		game.BroadcastMap()
-	}
-}
-
-func updateMap(game *entities.Game, cellX, cellY int, state types.Tile) {
-	msg := msgs.ShotMessage{
-		X:     cellX,
-		Y:     cellY,
-		State: state,
-	}
-	buf, _ := msg.Buffer()
-	by := buf.Bytes()
-	for _, player := range game.Players {
-		player.User.Send(by)
-	}
-}
-
 func main() {
 	app := fiber.New()
 
@@ -72,18 +32,20 @@ func main() {
 		Root: http.Dir("./public"),
 	}))
 
-	// Start a goroutine to periodically update the game state and broadcast updates
-	go func() {
-		i := 0
-		for range time.Tick(entities.GameTick) {
-			UpdateState()
-			BroadcastState()
-			if i%entities.MapTick == 0 {
-				BroadcastMap()
+	// The maps of the pool, for the host to pick from in the lobby
+	app.Get("/maps", func(c *fiber.Ctx) error {
+		pool := make([]fiber.Map, len(entities.MapPool))
+		for i, m := range entities.MapPool {
+			pool[i] = fiber.Map{
+				"id":     i + 1,
+				"name":   m.Name,
+				"author": m.Author,
+				"width":  m.GameMap.Width,
+				"height": m.GameMap.Height,
 			}
-			i++
 		}
-	}()
+		return c.JSON(pool)
+	})
 
 	// WebSocket upgrade middleware
 	app.Use("/ws", func(c *fiber.Ctx) error {
@@ -95,10 +57,21 @@ func main() {
 
 	// WebSocket route to handle real-time communication with clients
 	app.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		id := int16(rand.Int31() % 65536)
-		user := entities.NewUser(c, id, randomName())
-		cm := msgs.ConnectedMessage{ID: id, Username: user.Username}
-		user.SendMessage(cm)
+		c.EnableWriteCompression(false)
+		protocol, err := handshake(c)
+		if err != nil {
+			reject(c, err)
+			return
+		}
+		c.EnableWriteCompression(protocol.Has(msgs.FEATURE_COMPRESSION))
+
+		user, err := entities.NewUser(c, randomName(), protocol)
+		if err != nil {
+			reject(c, err)
+			return
+		}
+		id := user.ID
+		user.SendMessage(connected(user))
 
 		// websocket.Conn bindings https://pkg.go.dev/github.com/fasthttp/websocket?tab=doc#pkg-index
 		// Handle incoming messages from the client
@@ -115,7 +88,7 @@ func main() {
 				break
 			}
 
-			game := entities.FindUserInfo(id)
+			game := entities.Games.ByUser(id)
 
 			switch gmsg.Type {
 			case msgs.MSG_HOST:
@@ -127,8 +100,12 @@ func main() {
 				if game != nil {
 					user.Error("You are already in a game")
 				} else {
-					var wepon entities.Weapon = &wepons.Grenade{}
-					room := entities.NewGame(user, &wepon)
+					loadout := wepons.DefaultLoadout(entities.DefaultMatchSettings())
+					room, err := entities.NewGame(user, loadout)
+					if err != nil {
+						user.Error(err.Error())
+						continue
+					}
 					hosted := msgs.HostedMessage{Room: room}
 					user.SendMessage(hosted)
 				}
@@ -144,28 +121,41 @@ func main() {
 					break
 				}
 				room := strings.ToUpper(jm.Room)
-				game = entities.FindGameByRoom(room)
+				game = entities.Games.ByRoom(room)
 				if game == nil {
 					user.Error("Room not found")
-				} else {
-					var wepon entities.Weapon = &wepons.Grenade{}
-					err := game.AddUser(user, &wepon)
+					continue
+				}
+
+				ok = game.Do(func() {
+					loadout := wepons.DefaultLoadout(game.Settings)
+					err := game.AddUser(user, loadout)
 					if err != nil {
 						user.Error(err.Error())
-					} else {
-						jm := msgs.JoinedMessage{Room: room}
-						user.SendMessage(jm)
-						game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s joined the game", user.Username))
+						return
 					}
+					jm := msgs.JoinedMessage{Room: room}
+					user.SendMessage(jm)
+					game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s joined the game", user.Username))
+				})
+				if !ok {
+					user.Error("Room not found")
 				}
 			case msgs.MSG_LEAVE:
+				if game == nil {
+					user.Error("You are not in a game")
+					continue
+				}
+
 				_, ok := gmsg.ParseLeaveMessage()
 				if !ok {
 					log.Fatal("Unreachable: binarize left message")
 				}
-				game.RemovePlayer(id)
-				user.SendMessage(msgs.LeftMessage{})
-				game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s left the game", user.Username))
+				game.Do(func() {
+					game.RemovePlayer(id)
+					user.SendMessage(msgs.LeftMessage{})
+					game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s left the game", user.Username))
+				})
 			case msgs.MSG_START:
 				if game == nil {
 					user.Error("You are not in a game")
@@ -177,10 +167,56 @@ func main() {
 					log.Println("[ERROR]: ParseStartMessage", gmsg)
 				}
 
-				err := game.Start(id)
+				game.Do(func() {
+					err := game.Start(id)
+					if err != nil {
+						user.Error(err.Error())
+					}
+				})
+			case msgs.MSG_CONFIGURE:
+				if game == nil {
+					user.Error("You are not in a game")
+					continue
+				}
+
+				cm, ok := gmsg.ParseConfigureMessage()
+				if !ok {
+					log.Println("[ERROR]: ParseConfigureMessage", gmsg)
+					continue
+				}
+
+				settings := entities.SettingsFromMessage(cm)
+				game.Do(func() {
+					err := game.Configure(id, settings)
+					if err != nil {
+						user.Error(err.Error())
+						return
+					}
+					game.BroadcastSystem(msgs.SYS_MSG_INFO, "The host changed the match settings")
+				})
+			case msgs.MSG_ARM:
+				if game == nil {
+					user.Error("You are not in a game")
+					continue
+				}
+
+				am, ok := gmsg.ParseArmMessage()
+				if !ok {
+					log.Println("[ERROR]: ParseArmMessage", gmsg)
+					continue
+				}
+
+				wepon, err := wepons.New(am.WeaponId)
 				if err != nil {
 					user.Error(err.Error())
+					continue
 				}
+				game.Do(func() {
+					err := game.Arm(id, am.Slot, wepon)
+					if err != nil {
+						user.Error(err.Error())
+					}
+				})
 			case msgs.MSG_TEAM:
 				if game == nil {
 					user.Error("You are not in a game")
@@ -192,105 +228,152 @@ func main() {
 					log.Println("[ERROR]: ParseTeamMessage", gmsg)
 				}
 
-				err := game.SwitchTeams(id)
-				if err != nil {
-					user.Error(err.Error())
-				}
-				game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s switched teams", user.Username))
-			case msgs.MSG_MOVE:
+				game.Do(func() {
+					err := game.SwitchTeams(id)
+					if err != nil {
+						user.Error(err.Error())
+					}
+					game.BroadcastSystem(msgs.SYS_MSG_INFO, fmt.Sprintf("%s switched teams", user.Username))
+				})
+			case msgs.MSG_SWAP:
 				if game == nil {
 					user.Error("You are not in a game")
 					continue
 				}
-				if game.State.Phase != entities.Playing {
+
+				_, ok := gmsg.ParseSwapMessage()
+				if !ok {
+					log.Println("[ERROR]: ParseSwapMessage", gmsg)
+					continue
+				}
+
+				game.Do(func() {
+					err := game.Swap(id)
+					if err != nil {
+						user.Error(err.Error())
+					}
+				})
+			case msgs.MSG_MOVE:
+				if game == nil {
+					user.Error("You are not in a game")
 					continue
 				}
 
 				mm, ok := gmsg.ParseMoveMessage()
 				if !ok {
 					log.Println("[ERROR]: ParseMoveMessage", gmsg)
-				}
-
-				// Temp code
-				direction := ""
-				if mm.Up {
-					direction = "up"
-				} else if mm.Down {
-					direction = "down"
-				} else if mm.Left {
-					direction = "left"
-				} else if mm.Right {
-					direction = "right"
-				} else {
-					user.Error("[ERROR]: No Direction")
 					continue
 				}
-				start := mm.Start
-				game.MovePlayer(id, direction, start)
+
+				input := entities.Input{Up: mm.Up, Down: mm.Down, Left: mm.Left, Right: mm.Right}
+				game.Do(func() {
+					game.MovePlayer(id, mm.Seq, input)
+				})
 			case msgs.MSG_SHOOT:
 				if game == nil {
 					user.Error("You are not in a game")
 					continue
 				}
-				if game.State.Phase != entities.Playing {
-					continue
-				}
 
 				_, ok := gmsg.ParseShootMessage()
 				if !ok {
 					log.Println("[ERROR]: ParseShootMessage", gmsg)
 				}
 
-				cell, err := game.Shoot(id)
-				if err != nil {
-					user.Error(err.Error())
-				} else {
-					updateMap(game, cell.X, cell.Y, cell.State)
+				game.Do(func() {
+					if game.State.Phase != entities.Playing {
+						return
+					}
+					_, err := game.Shoot(id)
+					if err != nil {
+						user.Error(err.Error())
+					}
+				})
+			case msgs.MSG_WEAPONDOWN, msgs.MSG_WEAPONUPDATE, msgs.MSG_WEAPONUP:
+				if game == nil {
+					user.Error("You are not in a game")
+					continue
 				}
-			case msgs.MSG_WEAPONDOWN:
-				if game.State.Phase != entities.Playing {
+
+				game.Do(func() {
+					if game.State.Phase != entities.Playing {
+						return
+					}
+					var player = game.GetPlayer(id)
+					if player == nil {
+						return
+					}
+					event, ok := entities.ParseWeaponEvent(player, gmsg)
+					if !ok {
+						log.Println("[ERROR]: ParseWeaponEvent (", player.Weapon().Name(), ")", gmsg)
+						user.Error("Invalid weapon message")
+						return
+					}
+					err := event.Apply(&entities.WeaponContext{Game: game, Player: player})
+					if err != nil && !errors.Is(err, wepons.ErrThrottled) {
+						user.SendMessage(msgs.WeaponRejectedMessage{
+							Slot:     player.Active,
+							WeaponId: player.Weapon().Id(),
+							Reason:   err.Error(),
+						})
+					}
+				})
+			case msgs.MSG_STATEACK:
+				if game == nil {
 					continue
 				}
-				var player = game.GetPlayer(id)
-				var wepon = player.Weapon
-				message, ok := wepon.ParseWeaponDownMessage(gmsg)
+
+				am, ok := gmsg.ParseStateAckMessage()
 				if !ok {
-					log.Println("[ERROR]: ParseWeaponDownMessage (", wepon.Name(), ")", gmsg)
-				}
-				message["notifyOtherPlayers"] = notifyOtherPlayers
-				_, err := wepon.OnWeaponDown(game, player, message)
-				if err != nil {
-					user.Error(err.Error())
+					log.Println("[ERROR]: ParseStateAckMessage", gmsg)
+					continue
 				}
-			case msgs.MSG_WEAPONUPDATE:
-				if game.State.Phase != entities.Playing {
+
+				game.Do(func() {
+					game.AckState(id, am.Seq)
+				})
+			case msgs.MSG_MAPMISMATCH:
+				if game == nil {
 					continue
 				}
-				var player = game.GetPlayer(id)
-				var wepon = player.Weapon
-				message, ok := wepon.ParseWeaponUpdateMessage(gmsg)
+
+				_, ok := gmsg.ParseMapMismatchMessage()
 				if !ok {
-					log.Println("[ERROR]: ParseWeaponUpdateMessage (", wepon.Name(), ")", gmsg)
-				}
-				message["notifyOtherPlayers"] = notifyOtherPlayers
-				_, err := wepon.OnWeaponUpdate(game, player, message)
-				if err != nil {
-					user.Error(err.Error())
+					log.Println("[ERROR]: ParseMapMismatchMessage", gmsg)
+					continue
 				}
-			case msgs.MSG_WEAPONUP:
-				if game.State.Phase != entities.Playing {
+
+				game.Do(func() {
+					game.Resync(user)
+				})
+			case msgs.MSG_RESUME:
+				if game != nil {
+					user.Error("You are already in a game")
 					continue
 				}
-				var player = game.GetPlayer(id)
-				var wepon = player.Weapon
-				message, ok := wepon.ParseWeaponUpMessage(gmsg)
+
+				rm, ok := gmsg.ParseResumeMessage()
 				if !ok {
-					log.Println("[ERROR]: ParseWeaponUpMessage (", wepon.Name(), ")", gmsg)
+					log.Println("[ERROR]: ParseResumeMessage", gmsg)
+					continue
 				}
-				message["notifyOtherPlayers"] = notifyOtherPlayers
-				_, err := wepon.OnWeaponUp(game, player, message)
+
+				resumed, err := entities.Resume(rm.Token, user, c, protocol)
 				if err != nil {
 					user.Error(err.Error())
+					continue
+				}
+				// the user created for this connection is not needed anymore
+				user.Cleanup()
+				user = resumed
+				id = user.ID
+				user.SendMessage(connected(user))
+
+				game = entities.Games.ByUser(id)
+				if game != nil {
+					game.Do(func() {
+						game.Rejoin(user)
+					})
 				}
 			case msgs.MSG_CHAT:
 				// TODO: Add support for commands
@@ -308,7 +391,9 @@ func main() {
 					From:    id,
 				}
 
-				game.Broadcast(chm)
+				game.Do(func() {
+					game.Broadcast(chm)
+				})
 			default:
 				fmt.Println("Unknown message type", gmsg)
 				user.Error("Unknown message type")
@@ -316,16 +401,49 @@ func main() {
 		}
 
 		c.Close()
-		user.Cleanup()
-	}))
+		user.Disconnect(c)
+	}, websocket.Config{EnableCompression: true}))
 
 	log.Fatal(app.Listen(":3000"))
 }
 
-func notifyOtherPlayers(game *entities.Game, id int16, msg []byte) {
-	for _, p := range game.Players {
-		if p.User.ID != id {
-			p.User.Send(msg)
-		}
+// handshake waits for the client's hello and negotiates the protocol
+func handshake(c *websocket.Conn) (msgs.Negotiated, error) {
+	_, msg, err := c.ReadMessage()
+	if err != nil {
+		return msgs.Negotiated{}, err
+	}
+
+	gmsg, merr := msgs.ParseMessage(msg)
+	if merr != msgs.MessageNoError {
+		return msgs.Negotiated{}, errors.New("invalid handshake, please reload the page")
+	}
+
+	hello, ok := gmsg.ParseHelloMessage()
+	if !ok {
+		return msgs.Negotiated{}, errors.New("outdated client, please reload the page")
+	}
+
+	return msgs.Negotiate(hello)
+}
+
+// connected builds the ConnectedMessage for a new or resumed user
+func connected(user *entities.User) msgs.ConnectedMessage {
+	return msgs.ConnectedMessage{
+		ID:       user.ID,
+		Version:  user.Protocol.Version,
+		Features: user.Protocol.Features,
+		Token:    user.Token,
+		Username: user.Username,
+	}
+}
+
+// reject sends an error to a client that could not be let in and closes the connection
+func reject(c *websocket.Conn, err error) {
+	log.Println("rejected:", err)
+	buf, ok := msgs.ErrorMessage{Message: err.Error()}.Buffer()
+	if ok {
+		c.WriteMessage(websocket.BinaryMessage, buf.Bytes())
 	}
+	c.Close()
 }
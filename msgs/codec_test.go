@@ -0,0 +1,359 @@
+package msgs
+
+import (
+	"online-game/types"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// roundTrip encodes the message and parses it back into a GenericMessage
+func roundTrip(t *testing.T, m ServerMessage) GenericMessage {
+	t.Helper()
+	buf, ok := m.Buffer()
+	if !ok {
+		t.Fatal("could not encode the message")
+	}
+	gm, err := ParseMessage(buf.Bytes())
+	if err != MessageNoError {
+		t.Fatalf("ParseMessage: error %d", err)
+	}
+	return gm
+}
+
+// The decoders below follow the hand written ones of public/msgs.js
+
+func readPlayerFields(r *reader, player *types.StateMessagePlayer, mask uint16) {
+	if mask&DELTA_PLAYER_TEAM != 0 {
+		player.Team = types.TeamID(r.u8())
+	}
+	if mask&DELTA_PLAYER_X != 0 {
+		player.X = float64(r.u16()) / PositionScale
+	}
+	if mask&DELTA_PLAYER_Y != 0 {
+		player.Y = float64(r.u16()) / PositionScale
+	}
+	if mask&DELTA_PLAYER_VX != 0 {
+		player.VX = int32(r.i8())
+	}
+	if mask&DELTA_PLAYER_VY != 0 {
+		player.VY = int32(r.i8())
+	}
+	if mask&DELTA_PLAYER_WEAPON != 0 {
+		player.Active = types.Slot(r.u8())
+		for slot := range player.Loadout {
+			player.Loadout[slot] = types.LoadoutSlot{
+				WeaponId: types.WeaponId(r.u8()),
+				Weapon: types.WeaponStatus{
+					Cooldown: r.u16(),
+					Charge:   r.u8(),
+					Ammo:     r.u8(),
+				},
+			}
+		}
+	}
+	if mask&DELTA_PLAYER_JOINED != 0 {
+		player.User.Username = string(r.bytes(int(r.u8())))
+	}
+	if mask&DELTA_PLAYER_INPUT != 0 {
+		player.InputSeq = r.u32()
+	}
+	if mask&DELTA_PLAYER_INK != 0 {
+		player.Ink = r.u8()
+	}
+}
+
+func decodeState(t *testing.T, gm GenericMessage) StateMessage {
+	t.Helper()
+	if gm.Type != MSG_STATE {
+		t.Fatalf("type %d, want MSG_STATE", gm.Type)
+	}
+
+	r := newReader(gm.Args)
+	sm := StateMessage{
+		Seq:       r.u32(),
+		Host:      r.i16(),
+		Room:      string(r.bytes(4)),
+		Started:   r.bool(),
+		StartedAt: r.i32(),
+		State: types.StateMessageState{
+			TeamA:  r.i32(),
+			TeamB:  r.i32(),
+			ScoreA: r.i32(),
+			ScoreB: r.i32(),
+			Phase:  types.GamePhase(r.u8()),
+		},
+	}
+	for n := r.u8(); n > 0 && r.ok; n-- {
+		var player types.StateMessagePlayer
+		player.User.ID = r.i16()
+		readPlayerFields(r, &player, DELTA_PLAYER_ALL)
+		sm.Players = append(sm.Players, player)
+	}
+	if !r.done() {
+		t.Fatal("state message not read to its end")
+	}
+	return sm
+}
+
+// applyDelta decodes a delta and applies it to base, the snapshot it was
+// computed against
+func applyDelta(t *testing.T, gm GenericMessage, base StateMessage) StateMessage {
+	t.Helper()
+	if gm.Type != MSG_STATEDELTA {
+		t.Fatalf("type %d, want MSG_STATEDELTA", gm.Type)
+	}
+
+	r := newReader(gm.Args)
+	sm := base
+	sm.Seq = r.u32()
+	if baseSeq := r.u32(); baseSeq != base.Seq {
+		t.Fatalf("base seq %d, want %d", baseSeq, base.Seq)
+	}
+
+	mask := r.u8()
+	if mask&DELTA_STATE_HOST != 0 {
+		sm.Host = r.i16()
+	}
+	if mask&DELTA_STATE_STARTED != 0 {
+		sm.Started = r.bool()
+		sm.StartedAt = r.i32()
+	}
+	if mask&DELTA_STATE_TEAMS != 0 {
+		sm.State.TeamA = r.i32()
+		sm.State.TeamB = r.i32()
+	}
+	if mask&DELTA_STATE_SCORES != 0 {
+		sm.State.ScoreA = r.i32()
+		sm.State.ScoreB = r.i32()
+	}
+	if mask&DELTA_STATE_PHASE != 0 {
+		sm.State.Phase = types.GamePhase(r.u8())
+	}
+
+	players := map[int16]types.StateMessagePlayer{}
+	for _, player := range base.Players {
+		players[player.User.ID] = player
+	}
+	for n := r.u8(); n > 0 && r.ok; n-- {
+		delete(players, r.i16())
+	}
+	for n := r.u8(); n > 0 && r.ok; n-- {
+		id := r.i16()
+		pmask := r.u16()
+		player := players[id]
+		player.User.ID = id
+		readPlayerFields(r, &player, pmask)
+		players[id] = player
+	}
+	if !r.done() {
+		t.Fatal("delta message not read to its end")
+	}
+
+	sm.Players = nil
+	for _, player := range players {
+		sm.Players = append(sm.Players, player)
+	}
+	sortPlayers(sm.Players)
+	return sm
+}
+
+func sortPlayers(players []types.StateMessagePlayer) {
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].User.ID < players[j].User.ID
+	})
+}
+
+// samplePlayer has positions on the wire's fixed point grid, so they survive
+// quantization
+func samplePlayer(id int16, name string) types.StateMessagePlayer {
+	return types.StateMessagePlayer{
+		Team:   types.TeamID(id % 2),
+		X:      float64(id) + 0.5,
+		Y:      float64(id) + 0.25,
+		VX:     1,
+		VY:     -1,
+		User:   types.StateMessageUser{ID: id, Username: name},
+		Active: 1,
+		Loadout: [types.LoadoutSize]types.LoadoutSlot{
+			{WeaponId: 0, Weapon: types.WeaponStatus{Cooldown: 1500, Charge: 128, Ammo: 255}},
+			{WeaponId: 2, Weapon: types.WeaponStatus{Ammo: 10}},
+		},
+		Ink:      80,
+		InputSeq: uint32(id) * 1000,
+	}
+}
+
+func sampleState() StateMessage {
+	return StateMessage{
+		Seq:       7,
+		Host:      3,
+		Room:      "ABCD",
+		Started:   true,
+		StartedAt: 42,
+		State: types.StateMessageState{
+			TeamA:  0x6C946F,
+			TeamB:  0xDC0083,
+			ScoreA: 120,
+			ScoreB: 99,
+			Phase:  1,
+		},
+		Players: []types.StateMessagePlayer{
+			samplePlayer(3, "host"),
+			samplePlayer(4, "guest"),
+			samplePlayer(9, "späť"),
+		},
+	}
+}
+
+func TestRoundTripMapMessage(t *testing.T) {
+	want := types.GameMap{
+		Width:  3,
+		Height: 2,
+		Tiles:  []types.Tile{0, 1, 2, 3, 0, 1},
+	}
+
+	gm := roundTrip(t, MapMessage{Map: want})
+	if gm.Type != MSG_MAP {
+		t.Fatalf("type %d, want MSG_MAP", gm.Type)
+	}
+	r := newReader(gm.Args)
+	got := types.GameMap{Width: int(r.i32()), Height: int(r.i32())}
+	for _, tile := range r.bytes(got.Width * got.Height) {
+		got.Tiles = append(got.Tiles, types.Tile(tile))
+	}
+	if !r.done() {
+		t.Fatal("map message not read to its end")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripStateMessage(t *testing.T) {
+	want := sampleState()
+	got := decodeState(t, roundTrip(t, want))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStateMessageRoomLength(t *testing.T) {
+	sm := sampleState()
+	sm.Room = "ABC"
+	if _, ok := sm.Buffer(); ok {
+		t.Fatal("encoded a room code of the wrong length")
+	}
+}
+
+func TestRoundTripStateDeltaMessage(t *testing.T) {
+	base := sampleState()
+
+	moved := sampleState()
+	moved.Seq = 8
+	moved.Players[0].X += 1.0 / PositionScale
+	moved.Players[1].Ink = 40
+
+	changed := sampleState()
+	changed.Seq = 9
+	changed.Host = 4
+	changed.Started = false
+	changed.State.ScoreA = 130
+	changed.State.Phase = 2
+	changed.Players = []types.StateMessagePlayer{
+		samplePlayer(4, "guest"),
+		samplePlayer(12, "new"),
+	}
+	changed.Players[0].Active = 0
+	changed.Players[0].Loadout[1].Weapon.Charge = 200
+	changed.Players[0].VX = -1
+	changed.Players[0].InputSeq++
+
+	tests := map[string]StateMessage{
+		"unchanged":       base,
+		"moved":           moved,
+		"joined and left": changed,
+	}
+	for name, curr := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := applyDelta(t, roundTrip(t, StateDeltaMessage{Base: base, State: curr}), base)
+			want := curr
+			want.Players = append([]types.StateMessagePlayer(nil), curr.Players...)
+			sortPlayers(want.Players)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestStateDeltaMessageFromEmptyBase(t *testing.T) {
+	want := sampleState()
+	got := applyDelta(t, roundTrip(t, StateDeltaMessage{State: want}), StateMessage{})
+	// deltas never carry the room, it is the one of the first full state
+	want.Room = ""
+	want.Players = append([]types.StateMessagePlayer(nil), want.Players...)
+	sortPlayers(want.Players)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestDeltaConstantsMatchJS checks the bits and scales the client decodes
+// deltas with are the server's
+func TestDeltaConstantsMatchJS(t *testing.T) {
+	src, err := os.ReadFile("../public/msgs.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{
+		"DELTA_STATE_HOST":    int(DELTA_STATE_HOST),
+		"DELTA_STATE_STARTED": int(DELTA_STATE_STARTED),
+		"DELTA_STATE_TEAMS":   int(DELTA_STATE_TEAMS),
+		"DELTA_STATE_SCORES":  int(DELTA_STATE_SCORES),
+		"DELTA_STATE_PHASE":   int(DELTA_STATE_PHASE),
+		"DELTA_PLAYER_TEAM":   int(DELTA_PLAYER_TEAM),
+		"DELTA_PLAYER_X":      int(DELTA_PLAYER_X),
+		"DELTA_PLAYER_Y":      int(DELTA_PLAYER_Y),
+		"DELTA_PLAYER_VX":     int(DELTA_PLAYER_VX),
+		"DELTA_PLAYER_VY":     int(DELTA_PLAYER_VY),
+		"DELTA_PLAYER_WEAPON": int(DELTA_PLAYER_WEAPON),
+		"DELTA_PLAYER_JOINED": int(DELTA_PLAYER_JOINED),
+		"DELTA_PLAYER_INPUT":  int(DELTA_PLAYER_INPUT),
+		"DELTA_PLAYER_INK":    int(DELTA_PLAYER_INK),
+		"DELTA_PLAYER_ALL":    int(DELTA_PLAYER_ALL),
+		"LOADOUT_SIZE":        types.LoadoutSize,
+		"POSITION_SCALE":      PositionScale,
+		"PROTOCOL_VERSION":    int(PROTOCOL_VERSION),
+	}
+
+	// the forms the constants take in msgs.js: 256, 1 << 3 and (1 << 9) - 1
+	def := regexp.MustCompile(`(?m)^const (\w+) = (?:(\d+)|1 << (\d+)|\(1 << (\d+)\) - 1);`)
+	got := map[string]int{}
+	for _, m := range def.FindAllSubmatch(src, -1) {
+		var v int
+		switch {
+		case len(m[2]) > 0:
+			v, _ = strconv.Atoi(string(m[2]))
+		case len(m[3]) > 0:
+			n, _ := strconv.Atoi(string(m[3]))
+			v = 1 << n
+		default:
+			n, _ := strconv.Atoi(string(m[4]))
+			v = 1<<n - 1
+		}
+		got[string(m[1])] = v
+	}
+
+	for name, value := range want {
+		if js, ok := got[name]; !ok {
+			t.Errorf("%s is not defined in msgs.js", name)
+		} else if js != value {
+			t.Errorf("%s is %d in msgs.js, %d in Go", name, js, value)
+		}
+	}
+}
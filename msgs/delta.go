@@ -0,0 +1,195 @@
+package msgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"online-game/types"
+)
+
+// PositionScale is the fixed point scale positions are quantized to on the
+// wire: a position p is sent as the uint16 round(p * PositionScale).
+const PositionScale = 256
+
+// Fields of the game state carried by a StateDeltaMessage
+const (
+	DELTA_STATE_HOST    uint8 = 1 << iota
+	DELTA_STATE_STARTED uint8 = 1 << iota // Started and StartedAt
+	DELTA_STATE_TEAMS   uint8 = 1 << iota
+	DELTA_STATE_SCORES  uint8 = 1 << iota
+	DELTA_STATE_PHASE   uint8 = 1 << iota
+)
+
+// Fields of a player carried by a StateDeltaMessage
+const (
+	DELTA_PLAYER_TEAM   uint16 = 1 << iota
+	DELTA_PLAYER_X      uint16 = 1 << iota
+	DELTA_PLAYER_Y      uint16 = 1 << iota
+	DELTA_PLAYER_VX     uint16 = 1 << iota
+	DELTA_PLAYER_VY     uint16 = 1 << iota
+	DELTA_PLAYER_WEAPON uint16 = 1 << iota // Active and Loadout
+	DELTA_PLAYER_JOINED uint16 = 1 << iota // followed by the username
+	DELTA_PLAYER_INPUT  uint16 = 1 << iota
+	DELTA_PLAYER_INK    uint16 = 1 << iota
+
+	DELTA_PLAYER_ALL = DELTA_PLAYER_TEAM | DELTA_PLAYER_X | DELTA_PLAYER_Y |
+		DELTA_PLAYER_VX | DELTA_PLAYER_VY | DELTA_PLAYER_WEAPON | DELTA_PLAYER_JOINED |
+		DELTA_PLAYER_INPUT | DELTA_PLAYER_INK
+)
+
+// QuantizePosition converts a map position to its wire representation
+func QuantizePosition(p float64) uint16 {
+	q := math.Round(p * PositionScale)
+	return uint16(math.Min(math.Max(q, 0), math.MaxUint16))
+}
+
+func (dm StateDeltaMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+	base, curr := dm.Base, dm.State
+
+	buf.WriteByte(MSG_STATEDELTA)
+	binary.Write(buf, binary.LittleEndian, curr.Seq)
+	binary.Write(buf, binary.LittleEndian, base.Seq)
+
+	var mask uint8
+	if curr.Host != base.Host {
+		mask |= DELTA_STATE_HOST
+	}
+	if curr.Started != base.Started || curr.StartedAt != base.StartedAt {
+		mask |= DELTA_STATE_STARTED
+	}
+	if curr.State.TeamA != base.State.TeamA || curr.State.TeamB != base.State.TeamB {
+		mask |= DELTA_STATE_TEAMS
+	}
+	if curr.State.ScoreA != base.State.ScoreA || curr.State.ScoreB != base.State.ScoreB {
+		mask |= DELTA_STATE_SCORES
+	}
+	if curr.State.Phase != base.State.Phase {
+		mask |= DELTA_STATE_PHASE
+	}
+
+	buf.WriteByte(mask)
+	if mask&DELTA_STATE_HOST != 0 {
+		binary.Write(buf, binary.LittleEndian, curr.Host)
+	}
+	if mask&DELTA_STATE_STARTED != 0 {
+		binary.Write(buf, binary.LittleEndian, curr.Started)
+		binary.Write(buf, binary.LittleEndian, curr.StartedAt)
+	}
+	if mask&DELTA_STATE_TEAMS != 0 {
+		binary.Write(buf, binary.LittleEndian, curr.State.TeamA)
+		binary.Write(buf, binary.LittleEndian, curr.State.TeamB)
+	}
+	if mask&DELTA_STATE_SCORES != 0 {
+		binary.Write(buf, binary.LittleEndian, curr.State.ScoreA)
+		binary.Write(buf, binary.LittleEndian, curr.State.ScoreB)
+	}
+	if mask&DELTA_STATE_PHASE != 0 {
+		buf.WriteByte(byte(curr.State.Phase))
+	}
+
+	basePlayers := make(map[int16]types.StateMessagePlayer, len(base.Players))
+	for _, player := range base.Players {
+		basePlayers[player.User.ID] = player
+	}
+	currPlayers := make(map[int16]bool, len(curr.Players))
+	for _, player := range curr.Players {
+		currPlayers[player.User.ID] = true
+	}
+
+	removed := []int16{}
+	for _, player := range base.Players {
+		if !currPlayers[player.User.ID] {
+			removed = append(removed, player.User.ID)
+		}
+	}
+	buf.WriteByte(uint8(len(removed)))
+	for _, id := range removed {
+		binary.Write(buf, binary.LittleEndian, id)
+	}
+
+	changed := new(bytes.Buffer)
+	count := 0
+	for _, player := range curr.Players {
+		prev, ok := basePlayers[player.User.ID]
+		var pmask uint16
+		if !ok {
+			pmask = DELTA_PLAYER_ALL
+		} else {
+			pmask = playerDeltaMask(prev, player)
+		}
+		if pmask == 0 {
+			continue
+		}
+		count++
+		binary.Write(changed, binary.LittleEndian, player.User.ID)
+		binary.Write(changed, binary.LittleEndian, pmask)
+		writePlayerFields(changed, player, pmask)
+	}
+	buf.WriteByte(uint8(count))
+	buf.Write(changed.Bytes())
+
+	return buf, true
+}
+
+func playerDeltaMask(prev, curr types.StateMessagePlayer) uint16 {
+	var mask uint16
+	if prev.Team != curr.Team {
+		mask |= DELTA_PLAYER_TEAM
+	}
+	if QuantizePosition(prev.X) != QuantizePosition(curr.X) {
+		mask |= DELTA_PLAYER_X
+	}
+	if QuantizePosition(prev.Y) != QuantizePosition(curr.Y) {
+		mask |= DELTA_PLAYER_Y
+	}
+	if prev.VX != curr.VX {
+		mask |= DELTA_PLAYER_VX
+	}
+	if prev.VY != curr.VY {
+		mask |= DELTA_PLAYER_VY
+	}
+	if prev.Active != curr.Active || prev.Loadout != curr.Loadout {
+		mask |= DELTA_PLAYER_WEAPON
+	}
+	if prev.InputSeq != curr.InputSeq {
+		mask |= DELTA_PLAYER_INPUT
+	}
+	if prev.Ink != curr.Ink {
+		mask |= DELTA_PLAYER_INK
+	}
+	return mask
+}
+
+// writePlayerFields writes the player fields selected by mask
+func writePlayerFields(buf *bytes.Buffer, player types.StateMessagePlayer, mask uint16) {
+	if mask&DELTA_PLAYER_TEAM != 0 {
+		binary.Write(buf, binary.LittleEndian, player.Team)
+	}
+	if mask&DELTA_PLAYER_X != 0 {
+		binary.Write(buf, binary.LittleEndian, QuantizePosition(player.X))
+	}
+	if mask&DELTA_PLAYER_Y != 0 {
+		binary.Write(buf, binary.LittleEndian, QuantizePosition(player.Y))
+	}
+	if mask&DELTA_PLAYER_VX != 0 {
+		binary.Write(buf, binary.LittleEndian, int8(player.VX))
+	}
+	if mask&DELTA_PLAYER_VY != 0 {
+		binary.Write(buf, binary.LittleEndian, int8(player.VY))
+	}
+	if mask&DELTA_PLAYER_WEAPON != 0 {
+		binary.Write(buf, binary.LittleEndian, player.Active)
+		binary.Write(buf, binary.LittleEndian, player.Loadout)
+	}
+	if mask&DELTA_PLAYER_JOINED != 0 {
+		binary.Write(buf, binary.LittleEndian, uint8(len(player.User.Username)))
+		buf.WriteString(player.User.Username)
+	}
+	if mask&DELTA_PLAYER_INPUT != 0 {
+		binary.Write(buf, binary.LittleEndian, player.InputSeq)
+	}
+	if mask&DELTA_PLAYER_INK != 0 {
+		buf.WriteByte(player.Ink)
+	}
+}
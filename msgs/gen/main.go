@@ -0,0 +1,594 @@
+// Command gen generates the message codecs from msgs/schema.json: the Go
+// structs, encoders and decoders in msgs/messages_gen.go, round trip tests of
+// every message in msgs/messages_gen_test.go and the JavaScript codecs in
+// public/messages_gen.js.
+//
+// It runs from the msgs directory through go generate.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+)
+
+const schemaPath = "schema.json"
+const goPath = "messages_gen.go"
+const goTestPath = "messages_gen_test.go"
+const jsPath = "../public/messages_gen.js"
+
+const header = "Code generated by msgs/gen from msgs/schema.json. DO NOT EDIT."
+
+type Field struct {
+	Name  string   `json:"name"`
+	Type  string   `json:"type"`
+	Go    string   `json:"go"`    // Go type of the field when it differs from the wire type
+	Len   int      `json:"len"`   // fixed length of a string
+	Count string   `json:"count"` // wire type of an array's length
+	Bits  []string `json:"bits"`  // names of the bits of a flags field
+	Doc   string   `json:"doc"`   // trailing comment on the Go field
+}
+
+type Struct struct {
+	Name   string  `json:"name"`
+	Go     string  `json:"go"` // existing Go type the struct maps to
+	Fields []Field `json:"fields"`
+}
+
+type Message struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	From   string  `json:"from"`  // "server" or "client"
+	Codec  string  `json:"codec"` // "custom" when the codec is written by hand
+	Doc    string  `json:"doc"`
+	Fields []Field `json:"fields"`
+}
+
+type Schema struct {
+	Structs  []Struct  `json:"structs"`
+	Messages []Message `json:"messages"`
+}
+
+// scalars maps wire types to their Go type and the suffix of the JS
+// DataView accessors
+var scalars = map[string]struct{ goType, js string }{
+	"i8":   {"int8", "Int8"},
+	"u8":   {"uint8", "Uint8"},
+	"i16":  {"int16", "Int16"},
+	"u16":  {"uint16", "Uint16"},
+	"i32":  {"int32", "Int32"},
+	"u32":  {"uint32", "Uint32"},
+	"f64":  {"float64", "Float64"},
+	"bool": {"bool", "Boolean"},
+}
+
+func main() {
+	raw, err := os.ReadFile(schemaPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		log.Fatalf("%s: %v", schemaPath, err)
+	}
+	if err := schema.validate(); err != nil {
+		log.Fatalf("%s: %v", schemaPath, err)
+	}
+
+	src, err := format.Source([]byte(schema.goSource()))
+	if err != nil {
+		log.Fatalf("formatting %s: %v", goPath, err)
+	}
+	if err := os.WriteFile(goPath, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	testSrc, err := format.Source([]byte(schema.goTestSource()))
+	if err != nil {
+		log.Fatalf("formatting %s: %v", goTestPath, err)
+	}
+	if err := os.WriteFile(goTestPath, testSrc, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(jsPath, []byte(schema.jsSource()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *Schema) structByName(name string) *Struct {
+	for i := range s.Structs {
+		if s.Structs[i].Name == name {
+			return &s.Structs[i]
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validate() error {
+	for _, st := range s.Structs {
+		for _, f := range st.Fields {
+			if _, ok := scalars[f.Type]; !ok {
+				return fmt.Errorf("struct %s: field %s: only scalar fields are supported in structs", st.Name, f.Name)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, m := range s.Messages {
+		if seen[m.ID] {
+			return fmt.Errorf("duplicate message %s", m.ID)
+		}
+		seen[m.ID] = true
+
+		if m.From != "server" && m.From != "client" {
+			return fmt.Errorf("%s: from must be server or client", m.ID)
+		}
+		if m.Codec == "custom" {
+			if len(m.Fields) > 0 {
+				return fmt.Errorf("%s: custom messages describe no fields", m.ID)
+			}
+			continue
+		}
+
+		for i, f := range m.Fields {
+			switch {
+			case scalars[f.Type].goType != "":
+			case f.Type == "string", f.Type == "bytes", f.Type == "flags":
+			case f.Type == "rest":
+				if i != len(m.Fields)-1 {
+					return fmt.Errorf("%s: field %s: rest must be the last field", m.ID, f.Name)
+				}
+			case strings.HasPrefix(f.Type, "[]"):
+				if s.structByName(f.Type[2:]) == nil {
+					return fmt.Errorf("%s: field %s: unknown struct %s", m.ID, f.Name, f.Type[2:])
+				}
+				if f.Count != "u8" && f.Count != "u16" {
+					return fmt.Errorf("%s: field %s: count must be u8 or u16", m.ID, f.Name)
+				}
+			default:
+				return fmt.Errorf("%s: field %s: unknown type %s", m.ID, f.Name, f.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// jsName converts a Go field name to the name used by the JS decoders
+func jsName(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func maxCount(count string) int {
+	if count == "u16" {
+		return 65535
+	}
+	return 255
+}
+
+// Go
+
+func (s *Schema) goType(f Field) string {
+	if f.Go != "" {
+		return f.Go
+	}
+	if sc, ok := scalars[f.Type]; ok {
+		return sc.goType
+	}
+	switch f.Type {
+	case "string", "rest":
+		return "string"
+	case "bytes":
+		return "[]byte"
+	}
+	st := s.structByName(f.Type[2:])
+	if st.Go != "" {
+		return "[]" + st.Go
+	}
+	return f.Type
+}
+
+func (s *Schema) goSource() string {
+	b := &strings.Builder{}
+	b.WriteString("const (\n")
+	for _, m := range s.Messages {
+		fmt.Fprintf(b, "\t%s uint8 = iota\n", m.ID)
+	}
+	b.WriteString("\tMSG_LEN uint8 = iota\n)\n\n")
+
+	for _, st := range s.Structs {
+		if st.Go != "" {
+			continue
+		}
+		fmt.Fprintf(b, "type %s struct {\n", st.Name)
+		for _, f := range st.Fields {
+			fmt.Fprintf(b, "\t%s %s\n", f.Name, s.goType(f))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	for _, m := range s.Messages {
+		if m.Codec == "custom" {
+			continue
+		}
+		if m.Doc != "" {
+			for _, line := range strings.Split(m.Doc, "\n") {
+				fmt.Fprintf(b, "// %s\n", line)
+			}
+		}
+		if len(m.Fields) == 0 {
+			fmt.Fprintf(b, "type %s struct{}\n\n", m.Name)
+			continue
+		}
+		fmt.Fprintf(b, "type %s struct {\n", m.Name)
+		for _, f := range m.Fields {
+			if f.Type == "flags" {
+				for _, bit := range f.Bits {
+					fmt.Fprintf(b, "\t%s bool\n", bit)
+				}
+				continue
+			}
+			fmt.Fprintf(b, "\t%s %s", f.Name, s.goType(f))
+			if f.Doc != "" {
+				fmt.Fprintf(b, " // %s", f.Doc)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n\n")
+	}
+
+	// both ends of every message, the server only uses one of them but the
+	// tests round trip through both
+	for _, m := range s.Messages {
+		if m.Codec == "custom" {
+			continue
+		}
+		s.goEncoder(b, m)
+		s.goDecoder(b, m)
+	}
+
+	body := b.String()
+	imports := []string{`"bytes"`, `"encoding/binary"`}
+	if strings.Contains(body, "types.") {
+		imports = append(imports, `"online-game/types"`)
+	}
+	return fmt.Sprintf("// %s\n\npackage msgs\n\nimport (\n\t%s\n)\n\n%s",
+		header, strings.Join(imports, "\n\t"), body)
+}
+
+func goWriteScalar(b *strings.Builder, f Field, value string) {
+	if f.Go != "" {
+		value = fmt.Sprintf("%s(%s)", scalars[f.Type].goType, value)
+	}
+	fmt.Fprintf(b, "\tbinary.Write(buf, binary.LittleEndian, %s)\n", value)
+}
+
+func (s *Schema) goEncoder(b *strings.Builder, m Message) {
+	fmt.Fprintf(b, "func (m %s) Buffer() (*bytes.Buffer, bool) {\n", m.Name)
+	b.WriteString("\tbuf := new(bytes.Buffer)\n\n")
+	fmt.Fprintf(b, "\tbuf.WriteByte(%s)\n", m.ID)
+
+	for _, f := range m.Fields {
+		value := "m." + f.Name
+		switch {
+		case scalars[f.Type].goType != "":
+			goWriteScalar(b, f, value)
+		case f.Type == "string" && f.Len > 0:
+			fmt.Fprintf(b, "\tif len(%s) != %d {\n\t\treturn nil, false\n\t}\n", value, f.Len)
+			fmt.Fprintf(b, "\tbuf.WriteString(%s)\n", value)
+		case f.Type == "string", f.Type == "bytes":
+			fmt.Fprintf(b, "\tif len(%s) > 255 {\n\t\treturn nil, false\n\t}\n", value)
+			fmt.Fprintf(b, "\tbuf.WriteByte(uint8(len(%s)))\n", value)
+			if f.Type == "string" {
+				fmt.Fprintf(b, "\tbuf.WriteString(%s)\n", value)
+			} else {
+				fmt.Fprintf(b, "\tbuf.Write(%s)\n", value)
+			}
+		case f.Type == "rest":
+			fmt.Fprintf(b, "\tbuf.WriteString(%s)\n", value)
+		case f.Type == "flags":
+			b.WriteString("\tvar flags uint8\n")
+			for i, bit := range f.Bits {
+				fmt.Fprintf(b, "\tif m.%s {\n\t\tflags |= 1 << %d\n\t}\n", bit, i)
+			}
+			b.WriteString("\tbuf.WriteByte(flags)\n")
+		default:
+			st := s.structByName(f.Type[2:])
+			fmt.Fprintf(b, "\tif len(%s) > %d {\n\t\treturn nil, false\n\t}\n", value, maxCount(f.Count))
+			fmt.Fprintf(b, "\tbinary.Write(buf, binary.LittleEndian, %s(len(%s)))\n", scalars[f.Count].goType, value)
+			fmt.Fprintf(b, "\tfor _, v := range %s {\n", value)
+			for _, sf := range st.Fields {
+				goWriteScalar(b, sf, "v."+sf.Name)
+			}
+			b.WriteString("\t}\n")
+		}
+	}
+
+	b.WriteString("\n\treturn buf, true\n}\n\n")
+}
+
+func goReadScalar(f Field) string {
+	read := fmt.Sprintf("r.%s()", f.Type)
+	if f.Go != "" {
+		return fmt.Sprintf("%s(%s)", f.Go, read)
+	}
+	return read
+}
+
+func (s *Schema) goDecoder(b *strings.Builder, m Message) {
+	fmt.Fprintf(b, "func (gm GenericMessage) Parse%s() (%s, bool) {\n", m.Name, m.Name)
+	fmt.Fprintf(b, "\tif gm.Type != %s {\n\t\treturn %s{}, false\n\t}\n\n", m.ID, m.Name)
+
+	if len(m.Fields) == 0 {
+		fmt.Fprintf(b, "\tif len(gm.Args) > 0 {\n\t\treturn %s{}, false\n\t}\n\n", m.Name)
+		fmt.Fprintf(b, "\treturn %s{}, true\n}\n\n", m.Name)
+		return
+	}
+
+	b.WriteString("\tr := newReader(gm.Args)\n")
+	fmt.Fprintf(b, "\tvar m %s\n", m.Name)
+	for _, f := range m.Fields {
+		value := "m." + f.Name
+		switch {
+		case scalars[f.Type].goType != "":
+			fmt.Fprintf(b, "\t%s = %s\n", value, goReadScalar(f))
+		case f.Type == "string" && f.Len > 0:
+			fmt.Fprintf(b, "\t%s = string(r.bytes(%d))\n", value, f.Len)
+		case f.Type == "string":
+			fmt.Fprintf(b, "\t%s = string(r.bytes(int(r.u8())))\n", value)
+		case f.Type == "bytes":
+			fmt.Fprintf(b, "\t%s = r.bytes(int(r.u8()))\n", value)
+		case f.Type == "rest":
+			fmt.Fprintf(b, "\t%s = string(r.rest())\n", value)
+		case f.Type == "flags":
+			b.WriteString("\tflags := r.u8()\n")
+			for i, bit := range f.Bits {
+				fmt.Fprintf(b, "\tm.%s = flags&(1<<%d) != 0\n", bit, i)
+			}
+		default:
+			st := s.structByName(f.Type[2:])
+			item := st.Name
+			if st.Go != "" {
+				item = st.Go
+			}
+			fmt.Fprintf(b, "\tfor n := int(r.%s()); n > 0 && r.ok; n-- {\n", f.Count)
+			fmt.Fprintf(b, "\t\tvar v %s\n", item)
+			for _, sf := range st.Fields {
+				fmt.Fprintf(b, "\t\tv.%s = %s\n", sf.Name, goReadScalar(sf))
+			}
+			fmt.Fprintf(b, "\t\t%s = append(%s, v)\n\t}\n", value, value)
+		}
+	}
+	fmt.Fprintf(b, "\n\tif !r.done() {\n\t\treturn %s{}, false\n\t}\n\n", m.Name)
+	b.WriteString("\treturn m, true\n}\n\n")
+}
+
+// Go tests
+
+// goSample is a Go expression of a sample value for a scalar field, the n-th
+// of the message so that fields of the same type get different values
+func goSample(f Field, n int) string {
+	var v string
+	switch f.Type {
+	case "i8":
+		v = fmt.Sprint(-1 - n)
+	case "u8":
+		v = fmt.Sprint(1 + n)
+	case "i16":
+		v = fmt.Sprint(-1000 - n)
+	case "u16":
+		v = fmt.Sprint(1000 + n)
+	case "i32":
+		v = fmt.Sprint(-100000 - n)
+	case "u32":
+		v = fmt.Sprint(3000000000 + n)
+	case "f64":
+		v = fmt.Sprintf("%d.25", n)
+	case "bool":
+		return "true"
+	}
+	if f.Go != "" {
+		return fmt.Sprintf("%s(%s)", f.Go, v)
+	}
+	return v
+}
+
+func (s *Schema) goTestSource() string {
+	b := &strings.Builder{}
+	for _, m := range s.Messages {
+		if m.Codec == "custom" {
+			continue
+		}
+
+		fmt.Fprintf(b, "func TestRoundTrip%s(t *testing.T) {\n", m.Name)
+		fmt.Fprintf(b, "\twant := %s{\n", m.Name)
+		for n, f := range m.Fields {
+			switch {
+			case scalars[f.Type].goType != "":
+				fmt.Fprintf(b, "\t\t%s: %s,\n", f.Name, goSample(f, n))
+			case f.Type == "string" && f.Len > 0:
+				fmt.Fprintf(b, "\t\t%s: %q,\n", f.Name, strings.Repeat("Z", f.Len))
+			case f.Type == "string", f.Type == "rest":
+				fmt.Fprintf(b, "\t\t%s: %q,\n", f.Name, "sample "+f.Name)
+			case f.Type == "bytes":
+				fmt.Fprintf(b, "\t\t%s: []byte{1, 2, %d},\n", f.Name, n)
+			case f.Type == "flags":
+				for i, bit := range f.Bits {
+					fmt.Fprintf(b, "\t\t%s: %t,\n", bit, i%2 == 0)
+				}
+			default:
+				st := s.structByName(f.Type[2:])
+				fmt.Fprintf(b, "\t\t%s: %s{\n", f.Name, s.goType(f))
+				for item := 0; item < 2; item++ {
+					b.WriteString("\t\t\t{")
+					for i, sf := range st.Fields {
+						fmt.Fprintf(b, "%s: %s, ", sf.Name, goSample(sf, item*len(st.Fields)+i))
+					}
+					b.WriteString("},\n")
+				}
+				b.WriteString("\t\t},\n")
+			}
+		}
+		b.WriteString("\t}\n\n")
+		fmt.Fprintf(b, "\tgot, ok := roundTrip(t, want).Parse%s()\n", m.Name)
+		b.WriteString("\tif !ok {\n\t\tt.Fatal(\"could not parse the encoded message\")\n\t}\n")
+		b.WriteString("\tif !reflect.DeepEqual(got, want) {\n\t\tt.Fatalf(\"got %+v, want %+v\", got, want)\n\t}\n}\n\n")
+	}
+
+	body := b.String()
+	imports := []string{`"reflect"`, `"testing"`}
+	if strings.Contains(body, "types.") {
+		imports = append(imports, `"online-game/types"`)
+	}
+	return fmt.Sprintf("// %s\n\npackage msgs\n\nimport (\n\t%s\n)\n\n%s",
+		header, strings.Join(imports, "\n\t"), body)
+}
+
+// JS
+
+func (s *Schema) jsSource() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "// %s\n\n", header)
+
+	b.WriteString("// messages\nconst MESSAGES = {};\n\n")
+	for i, m := range s.Messages {
+		fmt.Fprintf(b, "MESSAGES[MESSAGES[%q] = %d] = %q;\n", m.ID, i, m.ID)
+	}
+	fmt.Fprintf(b, "MESSAGES[MESSAGES[%q] = %d] = %q;\n\n", "MSG_LEN", len(s.Messages), "MSG_LEN")
+
+	b.WriteString(`/**
+ * decodes a message the server sends, after its type byte
+ * @param {string} type
+ * @param {DataView} view
+ * @returns {object | null} null if the message has a hand written decoder
+ */
+function decodeGeneratedMsg(type, view) {
+    const state = { i: 0 };
+    const data = {};
+
+    switch (type) {
+`)
+	for _, m := range s.Messages {
+		if m.Codec == "custom" || m.From != "server" {
+			continue
+		}
+		fmt.Fprintf(b, "        case %q: {\n", m.ID)
+		for _, f := range m.Fields {
+			s.jsDecodeField(b, f, "data."+jsName(f.Name))
+		}
+		b.WriteString("        } break;\n")
+	}
+	b.WriteString(`        default:
+            return null;
+    }
+
+    return data;
+}
+
+/**
+ * encodes a message the client sends
+ * @param {{type: string, data: any}} msg
+ * @returns {Uint8Array | {error: string} | null} null if the message has a hand written encoder
+ */
+function encodeGeneratedMsg(msg) {
+    const w = new MsgWriter();
+    const data = msg.data || {};
+    w.Uint8(MESSAGES[msg.type]);
+
+    switch (msg.type) {
+`)
+	for _, m := range s.Messages {
+		if m.Codec == "custom" || m.From != "client" {
+			continue
+		}
+		fmt.Fprintf(b, "        case %q: {\n", m.ID)
+		for _, f := range m.Fields {
+			s.jsEncodeField(b, f)
+		}
+		b.WriteString("        } break;\n")
+	}
+	b.WriteString(`        default:
+            return null;
+    }
+
+    return w.bytes();
+}
+`)
+	return b.String()
+}
+
+func (s *Schema) jsDecodeField(b *strings.Builder, f Field, target string) {
+	const ind = "            "
+	switch {
+	case scalars[f.Type].goType != "":
+		fmt.Fprintf(b, "%s%s = get%s(view, state);\n", ind, target, scalars[f.Type].js)
+	case f.Type == "string" && f.Len > 0:
+		fmt.Fprintf(b, "%s%s = getString(view, %d, state);\n", ind, target, f.Len)
+	case f.Type == "string":
+		fmt.Fprintf(b, "%s%s = getString(view, getUint8(view, state), state);\n", ind, target)
+	case f.Type == "bytes":
+		fmt.Fprintf(b, "%s%s = getBytes(view, getUint8(view, state), state);\n", ind, target)
+	case f.Type == "rest":
+		fmt.Fprintf(b, "%s%s = getString(view, view.byteLength - state.i, state);\n", ind, target)
+	case f.Type == "flags":
+		fmt.Fprintf(b, "%sconst flags = getUint8(view, state);\n", ind)
+		for i, bit := range f.Bits {
+			fmt.Fprintf(b, "%sdata.%s = (flags & (1 << %d)) !== 0;\n", ind, jsName(bit), i)
+		}
+	default:
+		st := s.structByName(f.Type[2:])
+		fmt.Fprintf(b, "%s%s = [];\n", ind, target)
+		fmt.Fprintf(b, "%sfor (let n = get%s(view, state); n > 0; n--) {\n", ind, scalars[f.Count].js)
+		fmt.Fprintf(b, "%s    const item = {};\n", ind)
+		for _, sf := range st.Fields {
+			fmt.Fprintf(b, "%s    item.%s = get%s(view, state);\n", ind, jsName(sf.Name), scalars[sf.Type].js)
+		}
+		fmt.Fprintf(b, "%s    %s.push(item);\n%s}\n", ind, target, ind)
+	}
+}
+
+func (s *Schema) jsEncodeField(b *strings.Builder, f Field) {
+	const ind = "            "
+	value := "data." + jsName(f.Name)
+	switch {
+	case scalars[f.Type].goType != "":
+		fmt.Fprintf(b, "%sw.%s(%s);\n", ind, scalars[f.Type].js, value)
+	case f.Type == "string" && f.Len > 0:
+		fmt.Fprintf(b, "%sconst %s = new TextEncoder().encode(%s);\n", ind, jsName(f.Name), value)
+		fmt.Fprintf(b, "%sif (%s.length !== %d) return { error: %q };\n", ind, jsName(f.Name), f.Len, fmt.Sprintf("%s must be %d characters", f.Name, f.Len))
+		fmt.Fprintf(b, "%sw.raw(%s);\n", ind, jsName(f.Name))
+	case f.Type == "string", f.Type == "bytes":
+		if f.Type == "string" {
+			fmt.Fprintf(b, "%sconst %s = new TextEncoder().encode(%s);\n", ind, jsName(f.Name), value)
+		} else {
+			fmt.Fprintf(b, "%sconst %s = %s;\n", ind, jsName(f.Name), value)
+		}
+		fmt.Fprintf(b, "%sif (%s.length > 255) return { error: %q };\n", ind, jsName(f.Name), f.Name+" too long")
+		fmt.Fprintf(b, "%sw.Uint8(%s.length);\n", ind, jsName(f.Name))
+		fmt.Fprintf(b, "%sw.raw(%s);\n", ind, jsName(f.Name))
+	case f.Type == "rest":
+		fmt.Fprintf(b, "%sw.raw(new TextEncoder().encode(%s));\n", ind, value)
+	case f.Type == "flags":
+		bits := make([]string, len(f.Bits))
+		for i, bit := range f.Bits {
+			bits[i] = fmt.Sprintf("(data.%s ? 1 << %d : 0)", jsName(bit), i)
+		}
+		fmt.Fprintf(b, "%sw.Uint8(%s);\n", ind, strings.Join(bits, " | "))
+	default:
+		st := s.structByName(f.Type[2:])
+		fmt.Fprintf(b, "%sif (%s.length > %d) return { error: %q };\n", ind, value, maxCount(f.Count), f.Name+" too long")
+		fmt.Fprintf(b, "%sw.%s(%s.length);\n", ind, scalars[f.Count].js, value)
+		fmt.Fprintf(b, "%sfor (const item of %s) {\n", ind, value)
+		for _, sf := range st.Fields {
+			fmt.Fprintf(b, "%s    w.%s(item.%s);\n", ind, scalars[sf.Type].js, jsName(sf.Name))
+		}
+		fmt.Fprintf(b, "%s}\n", ind)
+	}
+}
@@ -0,0 +1,47 @@
+package msgs
+
+import "fmt"
+
+// PROTOCOL_VERSION is bumped whenever a message layout changes. Clients older
+// than MIN_PROTOCOL_VERSION are turned away during the handshake.
+const PROTOCOL_VERSION uint16 = 14
+const MIN_PROTOCOL_VERSION uint16 = 14
+
+// Optional protocol features a client can ask for in its HelloMessage
+const (
+	FEATURE_COMPRESSION uint8 = 1 << iota // permessage-deflate on server frames
+	FEATURE_DELTA_STATE uint8 = 1 << iota // MSG_STATEDELTA instead of full MSG_STATE every tick
+	FEATURE_JSON_DEBUG  uint8 = 1 << iota // a JSON text frame alongside every binary message
+
+	SUPPORTED_FEATURES = FEATURE_COMPRESSION | FEATURE_DELTA_STATE | FEATURE_JSON_DEBUG
+)
+
+// Negotiated is the result of the handshake: the protocol version spoken on
+// the connection and the features both sides agreed on. Handlers branch on it
+// through Has.
+type Negotiated struct {
+	Version  uint16
+	Features uint8
+}
+
+// Has reports whether feature was negotiated
+func (n Negotiated) Has(feature uint8) bool {
+	return n.Features&feature != 0
+}
+
+// Negotiate checks a client's hello against what the server speaks. It fails
+// if the client's protocol version is not supported, otherwise the result
+// holds the client's version and the requested features the server supports.
+func Negotiate(hello HelloMessage) (Negotiated, error) {
+	if hello.Version < MIN_PROTOCOL_VERSION || hello.Version > PROTOCOL_VERSION {
+		return Negotiated{}, fmt.Errorf(
+			"unsupported protocol version %d, the server speaks %d to %d: please reload the page",
+			hello.Version, MIN_PROTOCOL_VERSION, PROTOCOL_VERSION,
+		)
+	}
+
+	return Negotiated{
+		Version:  hello.Version,
+		Features: hello.Features & SUPPORTED_FEATURES,
+	}, nil
+}
@@ -0,0 +1,1236 @@
+// Code generated by msgs/gen from msgs/schema.json. DO NOT EDIT.
+
+package msgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"online-game/types"
+)
+
+const (
+	MSG_CNCT            uint8 = iota
+	MSG_HOST            uint8 = iota
+	MSG_HOSTED          uint8 = iota
+	MSG_JOIN            uint8 = iota
+	MSG_JOINED          uint8 = iota
+	MSG_LEAVE           uint8 = iota
+	MSG_LEFT            uint8 = iota
+	MSG_START           uint8 = iota
+	MSG_STARTED         uint8 = iota
+	MSG_TEAM            uint8 = iota
+	MSG_TEAMED          uint8 = iota
+	MSG_MOVE            uint8 = iota
+	MSG_MOVED           uint8 = iota
+	MSG_SHOOT           uint8 = iota
+	MSG_SHOT            uint8 = iota
+	MSG_CHAT            uint8 = iota
+	MSG_CHATTED         uint8 = iota
+	MSG_MAP             uint8 = iota
+	MSG_STATE           uint8 = iota
+	MSG_SYSTEM          uint8 = iota
+	MSG_ERROR           uint8 = iota
+	MSG_WEAPONDOWN      uint8 = iota
+	MSG_WEAPONUPDATE    uint8 = iota
+	MSG_WEAPONUP        uint8 = iota
+	MSG_WEAPONPRESSED   uint8 = iota
+	MSG_WEAPONUPDATED   uint8 = iota
+	MSG_WEAPONRELEASED  uint8 = iota
+	MSG_STATEDELTA      uint8 = iota
+	MSG_STATEACK        uint8 = iota
+	MSG_TILES           uint8 = iota
+	MSG_MAPMISMATCH     uint8 = iota
+	MSG_HELLO           uint8 = iota
+	MSG_RESUME          uint8 = iota
+	MSG_CONFIGURE       uint8 = iota
+	MSG_CONFIGURED      uint8 = iota
+	MSG_ARM             uint8 = iota
+	MSG_ENTITYSPAWNED   uint8 = iota
+	MSG_ENTITYDESPAWNED uint8 = iota
+	MSG_ENTITYTRIGGERED uint8 = iota
+	MSG_WEAPONREJECTED  uint8 = iota
+	MSG_SWAP            uint8 = iota
+	MSG_LEN             uint8 = iota
+)
+
+// ConnectedMessage answers a HelloMessage with the negotiated protocol,
+// and a ResumeMessage with the resumed session
+type ConnectedMessage struct {
+	ID       int16
+	Version  uint16
+	Features uint8
+	Token    string
+	Username string
+}
+
+type HostMessage struct{}
+
+type HostedMessage struct {
+	Room string
+}
+
+type JoinMessage struct {
+	Room string
+}
+
+type JoinedMessage struct {
+	Room string
+}
+
+type LeaveMessage struct{}
+
+type LeftMessage struct{}
+
+type StartMessage struct{}
+
+type StartedMessage struct{}
+
+type TeamMessage struct{}
+
+type TeamedMessage struct{}
+
+// MoveMessage carries the directions held as of input Seq
+type MoveMessage struct {
+	Seq   uint32
+	Up    bool
+	Down  bool
+	Left  bool
+	Right bool
+}
+
+type MovedMessage struct{}
+
+type ShootMessage struct{}
+
+type ShotMessage struct {
+	X     int32
+	Y     int32
+	State types.Tile
+}
+
+type ChatMessage struct {
+	Message string
+}
+
+type ChattedMessage struct {
+	From    int16
+	Message string
+}
+
+type SystemMessage struct {
+	Type    uint8
+	Message string
+}
+
+type ErrorMessage struct {
+	Message string
+}
+
+type WeaponPressedMessage struct {
+	WeaponId types.WeaponId
+	PlayerId int16
+	Args     []byte
+}
+
+type WeaponUpdatedMessage struct {
+	WeaponId types.WeaponId
+	PlayerId int16
+	Args     []byte
+}
+
+type WeaponReleasedMessage struct {
+	WeaponId types.WeaponId
+	PlayerId int16
+	Args     []byte
+}
+
+type StateAckMessage struct {
+	Seq uint32
+}
+
+// TilesMessage batches the tiles changed during a tick. Checksum is the
+// checksum of the whole map once the changes are applied.
+type TilesMessage struct {
+	Checksum uint32
+	Tiles    []types.TileChange
+}
+
+type MapMismatchMessage struct{}
+
+// HelloMessage is the first message a client sends, before anything else
+type HelloMessage struct {
+	Version  uint16
+	Features uint8
+}
+
+// ResumeMessage reattaches the connection to the session Token was issued for
+type ResumeMessage struct {
+	Token string
+}
+
+// ConfigureMessage asks for new match settings, only the host may send it
+type ConfigureMessage struct {
+	Duration     uint16 // seconds
+	MaxPlayers   uint8
+	PlayerSpeed  uint8
+	MapWidth     uint8
+	MapHeight    uint8
+	MapDivisions uint8
+	Weapons      uint8  // bit per allowed weapon id
+	Seed         uint32 // the same seed generates the same map
+	Map          uint8  // index in the map pool plus one, 0 generates the map
+	Symmetry     uint8  // how a generated map mirrors its halves, 0 for none
+	Generator    uint8  // 0 splits the map with wall lines, 1 digs caves, 2 lays a maze
+}
+
+// ConfiguredMessage carries the match settings of the room
+type ConfiguredMessage struct {
+	Duration     uint16 // seconds
+	MaxPlayers   uint8
+	PlayerSpeed  uint8
+	MapWidth     uint8
+	MapHeight    uint8
+	MapDivisions uint8
+	Weapons      uint8  // bit per allowed weapon id
+	Seed         uint32 // the same seed generates the same map
+	Map          uint8  // index in the map pool plus one, 0 generates the map
+	Symmetry     uint8  // how a generated map mirrors its halves, 0 for none
+	Generator    uint8  // 0 splits the map with wall lines, 1 digs caves, 2 lays a maze
+}
+
+// ArmMessage picks the weapon in a slot of the player's loadout in the lobby
+type ArmMessage struct {
+	Slot     types.Slot
+	WeaponId types.WeaponId
+}
+
+// EntitySpawnedMessage announces a world entity, like a placed mine
+type EntitySpawnedMessage struct {
+	EntityId uint16
+	Kind     types.EntityKind
+	Team     types.TeamID
+	X        float64
+	Y        float64
+	Args     []byte // depends on the kind
+}
+
+type EntityDespawnedMessage struct {
+	EntityId uint16
+}
+
+// EntityTriggeredMessage tells an entity went off at X, Y, like a grenade
+// landing. It is followed by the entity's EntityDespawnedMessage.
+type EntityTriggeredMessage struct {
+	EntityId uint16
+	X        float64
+	Y        float64
+}
+
+// WeaponRejectedMessage tells a player the server did not take their weapon
+// message, and why
+type WeaponRejectedMessage struct {
+	Slot     types.Slot
+	WeaponId types.WeaponId
+	Reason   string
+}
+
+// SwapMessage switches to the weapon in the player's other slot
+type SwapMessage struct{}
+
+func (m ConnectedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_CNCT)
+	binary.Write(buf, binary.LittleEndian, m.ID)
+	binary.Write(buf, binary.LittleEndian, m.Version)
+	binary.Write(buf, binary.LittleEndian, m.Features)
+	if len(m.Token) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Token)))
+	buf.WriteString(m.Token)
+	buf.WriteString(m.Username)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseConnectedMessage() (ConnectedMessage, bool) {
+	if gm.Type != MSG_CNCT {
+		return ConnectedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ConnectedMessage
+	m.ID = r.i16()
+	m.Version = r.u16()
+	m.Features = r.u8()
+	m.Token = string(r.bytes(int(r.u8())))
+	m.Username = string(r.rest())
+
+	if !r.done() {
+		return ConnectedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m HostMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_HOST)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseHostMessage() (HostMessage, bool) {
+	if gm.Type != MSG_HOST {
+		return HostMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return HostMessage{}, false
+	}
+
+	return HostMessage{}, true
+}
+
+func (m HostedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_HOSTED)
+	if len(m.Room) != 4 {
+		return nil, false
+	}
+	buf.WriteString(m.Room)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseHostedMessage() (HostedMessage, bool) {
+	if gm.Type != MSG_HOSTED {
+		return HostedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m HostedMessage
+	m.Room = string(r.bytes(4))
+
+	if !r.done() {
+		return HostedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m JoinMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_JOIN)
+	if len(m.Room) != 4 {
+		return nil, false
+	}
+	buf.WriteString(m.Room)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseJoinMessage() (JoinMessage, bool) {
+	if gm.Type != MSG_JOIN {
+		return JoinMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m JoinMessage
+	m.Room = string(r.bytes(4))
+
+	if !r.done() {
+		return JoinMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m JoinedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_JOINED)
+	if len(m.Room) != 4 {
+		return nil, false
+	}
+	buf.WriteString(m.Room)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseJoinedMessage() (JoinedMessage, bool) {
+	if gm.Type != MSG_JOINED {
+		return JoinedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m JoinedMessage
+	m.Room = string(r.bytes(4))
+
+	if !r.done() {
+		return JoinedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m LeaveMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_LEAVE)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseLeaveMessage() (LeaveMessage, bool) {
+	if gm.Type != MSG_LEAVE {
+		return LeaveMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return LeaveMessage{}, false
+	}
+
+	return LeaveMessage{}, true
+}
+
+func (m LeftMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_LEFT)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseLeftMessage() (LeftMessage, bool) {
+	if gm.Type != MSG_LEFT {
+		return LeftMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return LeftMessage{}, false
+	}
+
+	return LeftMessage{}, true
+}
+
+func (m StartMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_START)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseStartMessage() (StartMessage, bool) {
+	if gm.Type != MSG_START {
+		return StartMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return StartMessage{}, false
+	}
+
+	return StartMessage{}, true
+}
+
+func (m StartedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_STARTED)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseStartedMessage() (StartedMessage, bool) {
+	if gm.Type != MSG_STARTED {
+		return StartedMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return StartedMessage{}, false
+	}
+
+	return StartedMessage{}, true
+}
+
+func (m TeamMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_TEAM)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseTeamMessage() (TeamMessage, bool) {
+	if gm.Type != MSG_TEAM {
+		return TeamMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return TeamMessage{}, false
+	}
+
+	return TeamMessage{}, true
+}
+
+func (m TeamedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_TEAMED)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseTeamedMessage() (TeamedMessage, bool) {
+	if gm.Type != MSG_TEAMED {
+		return TeamedMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return TeamedMessage{}, false
+	}
+
+	return TeamedMessage{}, true
+}
+
+func (m MoveMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_MOVE)
+	binary.Write(buf, binary.LittleEndian, m.Seq)
+	var flags uint8
+	if m.Up {
+		flags |= 1 << 0
+	}
+	if m.Down {
+		flags |= 1 << 1
+	}
+	if m.Left {
+		flags |= 1 << 2
+	}
+	if m.Right {
+		flags |= 1 << 3
+	}
+	buf.WriteByte(flags)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseMoveMessage() (MoveMessage, bool) {
+	if gm.Type != MSG_MOVE {
+		return MoveMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m MoveMessage
+	m.Seq = r.u32()
+	flags := r.u8()
+	m.Up = flags&(1<<0) != 0
+	m.Down = flags&(1<<1) != 0
+	m.Left = flags&(1<<2) != 0
+	m.Right = flags&(1<<3) != 0
+
+	if !r.done() {
+		return MoveMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m MovedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_MOVED)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseMovedMessage() (MovedMessage, bool) {
+	if gm.Type != MSG_MOVED {
+		return MovedMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return MovedMessage{}, false
+	}
+
+	return MovedMessage{}, true
+}
+
+func (m ShootMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_SHOOT)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseShootMessage() (ShootMessage, bool) {
+	if gm.Type != MSG_SHOOT {
+		return ShootMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return ShootMessage{}, false
+	}
+
+	return ShootMessage{}, true
+}
+
+func (m ShotMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_SHOT)
+	binary.Write(buf, binary.LittleEndian, m.X)
+	binary.Write(buf, binary.LittleEndian, m.Y)
+	binary.Write(buf, binary.LittleEndian, uint8(m.State))
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseShotMessage() (ShotMessage, bool) {
+	if gm.Type != MSG_SHOT {
+		return ShotMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ShotMessage
+	m.X = r.i32()
+	m.Y = r.i32()
+	m.State = types.Tile(r.u8())
+
+	if !r.done() {
+		return ShotMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ChatMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_CHAT)
+	if len(m.Message) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Message)))
+	buf.WriteString(m.Message)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseChatMessage() (ChatMessage, bool) {
+	if gm.Type != MSG_CHAT {
+		return ChatMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ChatMessage
+	m.Message = string(r.bytes(int(r.u8())))
+
+	if !r.done() {
+		return ChatMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ChattedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_CHATTED)
+	binary.Write(buf, binary.LittleEndian, m.From)
+	if len(m.Message) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Message)))
+	buf.WriteString(m.Message)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseChattedMessage() (ChattedMessage, bool) {
+	if gm.Type != MSG_CHATTED {
+		return ChattedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ChattedMessage
+	m.From = r.i16()
+	m.Message = string(r.bytes(int(r.u8())))
+
+	if !r.done() {
+		return ChattedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m SystemMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_SYSTEM)
+	binary.Write(buf, binary.LittleEndian, m.Type)
+	if len(m.Message) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Message)))
+	buf.WriteString(m.Message)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseSystemMessage() (SystemMessage, bool) {
+	if gm.Type != MSG_SYSTEM {
+		return SystemMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m SystemMessage
+	m.Type = r.u8()
+	m.Message = string(r.bytes(int(r.u8())))
+
+	if !r.done() {
+		return SystemMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ErrorMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_ERROR)
+	if len(m.Message) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Message)))
+	buf.WriteString(m.Message)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseErrorMessage() (ErrorMessage, bool) {
+	if gm.Type != MSG_ERROR {
+		return ErrorMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ErrorMessage
+	m.Message = string(r.bytes(int(r.u8())))
+
+	if !r.done() {
+		return ErrorMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m WeaponPressedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_WEAPONPRESSED)
+	binary.Write(buf, binary.LittleEndian, uint8(m.WeaponId))
+	binary.Write(buf, binary.LittleEndian, m.PlayerId)
+	if len(m.Args) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Args)))
+	buf.Write(m.Args)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseWeaponPressedMessage() (WeaponPressedMessage, bool) {
+	if gm.Type != MSG_WEAPONPRESSED {
+		return WeaponPressedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m WeaponPressedMessage
+	m.WeaponId = types.WeaponId(r.u8())
+	m.PlayerId = r.i16()
+	m.Args = r.bytes(int(r.u8()))
+
+	if !r.done() {
+		return WeaponPressedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m WeaponUpdatedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_WEAPONUPDATED)
+	binary.Write(buf, binary.LittleEndian, uint8(m.WeaponId))
+	binary.Write(buf, binary.LittleEndian, m.PlayerId)
+	if len(m.Args) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Args)))
+	buf.Write(m.Args)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseWeaponUpdatedMessage() (WeaponUpdatedMessage, bool) {
+	if gm.Type != MSG_WEAPONUPDATED {
+		return WeaponUpdatedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m WeaponUpdatedMessage
+	m.WeaponId = types.WeaponId(r.u8())
+	m.PlayerId = r.i16()
+	m.Args = r.bytes(int(r.u8()))
+
+	if !r.done() {
+		return WeaponUpdatedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m WeaponReleasedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_WEAPONRELEASED)
+	binary.Write(buf, binary.LittleEndian, uint8(m.WeaponId))
+	binary.Write(buf, binary.LittleEndian, m.PlayerId)
+	if len(m.Args) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Args)))
+	buf.Write(m.Args)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseWeaponReleasedMessage() (WeaponReleasedMessage, bool) {
+	if gm.Type != MSG_WEAPONRELEASED {
+		return WeaponReleasedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m WeaponReleasedMessage
+	m.WeaponId = types.WeaponId(r.u8())
+	m.PlayerId = r.i16()
+	m.Args = r.bytes(int(r.u8()))
+
+	if !r.done() {
+		return WeaponReleasedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m StateAckMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_STATEACK)
+	binary.Write(buf, binary.LittleEndian, m.Seq)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseStateAckMessage() (StateAckMessage, bool) {
+	if gm.Type != MSG_STATEACK {
+		return StateAckMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m StateAckMessage
+	m.Seq = r.u32()
+
+	if !r.done() {
+		return StateAckMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m TilesMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_TILES)
+	binary.Write(buf, binary.LittleEndian, m.Checksum)
+	if len(m.Tiles) > 65535 {
+		return nil, false
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(len(m.Tiles)))
+	for _, v := range m.Tiles {
+		binary.Write(buf, binary.LittleEndian, uint16(v.X))
+		binary.Write(buf, binary.LittleEndian, uint16(v.Y))
+		binary.Write(buf, binary.LittleEndian, uint8(v.Tile))
+	}
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseTilesMessage() (TilesMessage, bool) {
+	if gm.Type != MSG_TILES {
+		return TilesMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m TilesMessage
+	m.Checksum = r.u32()
+	for n := int(r.u16()); n > 0 && r.ok; n-- {
+		var v types.TileChange
+		v.X = int(r.u16())
+		v.Y = int(r.u16())
+		v.Tile = types.Tile(r.u8())
+		m.Tiles = append(m.Tiles, v)
+	}
+
+	if !r.done() {
+		return TilesMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m MapMismatchMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_MAPMISMATCH)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseMapMismatchMessage() (MapMismatchMessage, bool) {
+	if gm.Type != MSG_MAPMISMATCH {
+		return MapMismatchMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return MapMismatchMessage{}, false
+	}
+
+	return MapMismatchMessage{}, true
+}
+
+func (m HelloMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_HELLO)
+	binary.Write(buf, binary.LittleEndian, m.Version)
+	binary.Write(buf, binary.LittleEndian, m.Features)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseHelloMessage() (HelloMessage, bool) {
+	if gm.Type != MSG_HELLO {
+		return HelloMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m HelloMessage
+	m.Version = r.u16()
+	m.Features = r.u8()
+
+	if !r.done() {
+		return HelloMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ResumeMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_RESUME)
+	if len(m.Token) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Token)))
+	buf.WriteString(m.Token)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseResumeMessage() (ResumeMessage, bool) {
+	if gm.Type != MSG_RESUME {
+		return ResumeMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ResumeMessage
+	m.Token = string(r.bytes(int(r.u8())))
+
+	if !r.done() {
+		return ResumeMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ConfigureMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_CONFIGURE)
+	binary.Write(buf, binary.LittleEndian, m.Duration)
+	binary.Write(buf, binary.LittleEndian, m.MaxPlayers)
+	binary.Write(buf, binary.LittleEndian, m.PlayerSpeed)
+	binary.Write(buf, binary.LittleEndian, m.MapWidth)
+	binary.Write(buf, binary.LittleEndian, m.MapHeight)
+	binary.Write(buf, binary.LittleEndian, m.MapDivisions)
+	binary.Write(buf, binary.LittleEndian, m.Weapons)
+	binary.Write(buf, binary.LittleEndian, m.Seed)
+	binary.Write(buf, binary.LittleEndian, m.Map)
+	binary.Write(buf, binary.LittleEndian, m.Symmetry)
+	binary.Write(buf, binary.LittleEndian, m.Generator)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseConfigureMessage() (ConfigureMessage, bool) {
+	if gm.Type != MSG_CONFIGURE {
+		return ConfigureMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ConfigureMessage
+	m.Duration = r.u16()
+	m.MaxPlayers = r.u8()
+	m.PlayerSpeed = r.u8()
+	m.MapWidth = r.u8()
+	m.MapHeight = r.u8()
+	m.MapDivisions = r.u8()
+	m.Weapons = r.u8()
+	m.Seed = r.u32()
+	m.Map = r.u8()
+	m.Symmetry = r.u8()
+	m.Generator = r.u8()
+
+	if !r.done() {
+		return ConfigureMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ConfiguredMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_CONFIGURED)
+	binary.Write(buf, binary.LittleEndian, m.Duration)
+	binary.Write(buf, binary.LittleEndian, m.MaxPlayers)
+	binary.Write(buf, binary.LittleEndian, m.PlayerSpeed)
+	binary.Write(buf, binary.LittleEndian, m.MapWidth)
+	binary.Write(buf, binary.LittleEndian, m.MapHeight)
+	binary.Write(buf, binary.LittleEndian, m.MapDivisions)
+	binary.Write(buf, binary.LittleEndian, m.Weapons)
+	binary.Write(buf, binary.LittleEndian, m.Seed)
+	binary.Write(buf, binary.LittleEndian, m.Map)
+	binary.Write(buf, binary.LittleEndian, m.Symmetry)
+	binary.Write(buf, binary.LittleEndian, m.Generator)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseConfiguredMessage() (ConfiguredMessage, bool) {
+	if gm.Type != MSG_CONFIGURED {
+		return ConfiguredMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ConfiguredMessage
+	m.Duration = r.u16()
+	m.MaxPlayers = r.u8()
+	m.PlayerSpeed = r.u8()
+	m.MapWidth = r.u8()
+	m.MapHeight = r.u8()
+	m.MapDivisions = r.u8()
+	m.Weapons = r.u8()
+	m.Seed = r.u32()
+	m.Map = r.u8()
+	m.Symmetry = r.u8()
+	m.Generator = r.u8()
+
+	if !r.done() {
+		return ConfiguredMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m ArmMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_ARM)
+	binary.Write(buf, binary.LittleEndian, uint8(m.Slot))
+	binary.Write(buf, binary.LittleEndian, uint8(m.WeaponId))
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseArmMessage() (ArmMessage, bool) {
+	if gm.Type != MSG_ARM {
+		return ArmMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m ArmMessage
+	m.Slot = types.Slot(r.u8())
+	m.WeaponId = types.WeaponId(r.u8())
+
+	if !r.done() {
+		return ArmMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m EntitySpawnedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_ENTITYSPAWNED)
+	binary.Write(buf, binary.LittleEndian, m.EntityId)
+	binary.Write(buf, binary.LittleEndian, uint8(m.Kind))
+	binary.Write(buf, binary.LittleEndian, uint8(m.Team))
+	binary.Write(buf, binary.LittleEndian, m.X)
+	binary.Write(buf, binary.LittleEndian, m.Y)
+	if len(m.Args) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Args)))
+	buf.Write(m.Args)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseEntitySpawnedMessage() (EntitySpawnedMessage, bool) {
+	if gm.Type != MSG_ENTITYSPAWNED {
+		return EntitySpawnedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m EntitySpawnedMessage
+	m.EntityId = r.u16()
+	m.Kind = types.EntityKind(r.u8())
+	m.Team = types.TeamID(r.u8())
+	m.X = r.f64()
+	m.Y = r.f64()
+	m.Args = r.bytes(int(r.u8()))
+
+	if !r.done() {
+		return EntitySpawnedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m EntityDespawnedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_ENTITYDESPAWNED)
+	binary.Write(buf, binary.LittleEndian, m.EntityId)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseEntityDespawnedMessage() (EntityDespawnedMessage, bool) {
+	if gm.Type != MSG_ENTITYDESPAWNED {
+		return EntityDespawnedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m EntityDespawnedMessage
+	m.EntityId = r.u16()
+
+	if !r.done() {
+		return EntityDespawnedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m EntityTriggeredMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_ENTITYTRIGGERED)
+	binary.Write(buf, binary.LittleEndian, m.EntityId)
+	binary.Write(buf, binary.LittleEndian, m.X)
+	binary.Write(buf, binary.LittleEndian, m.Y)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseEntityTriggeredMessage() (EntityTriggeredMessage, bool) {
+	if gm.Type != MSG_ENTITYTRIGGERED {
+		return EntityTriggeredMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m EntityTriggeredMessage
+	m.EntityId = r.u16()
+	m.X = r.f64()
+	m.Y = r.f64()
+
+	if !r.done() {
+		return EntityTriggeredMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m WeaponRejectedMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_WEAPONREJECTED)
+	binary.Write(buf, binary.LittleEndian, uint8(m.Slot))
+	binary.Write(buf, binary.LittleEndian, uint8(m.WeaponId))
+	if len(m.Reason) > 255 {
+		return nil, false
+	}
+	buf.WriteByte(uint8(len(m.Reason)))
+	buf.WriteString(m.Reason)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseWeaponRejectedMessage() (WeaponRejectedMessage, bool) {
+	if gm.Type != MSG_WEAPONREJECTED {
+		return WeaponRejectedMessage{}, false
+	}
+
+	r := newReader(gm.Args)
+	var m WeaponRejectedMessage
+	m.Slot = types.Slot(r.u8())
+	m.WeaponId = types.WeaponId(r.u8())
+	m.Reason = string(r.bytes(int(r.u8())))
+
+	if !r.done() {
+		return WeaponRejectedMessage{}, false
+	}
+
+	return m, true
+}
+
+func (m SwapMessage) Buffer() (*bytes.Buffer, bool) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(MSG_SWAP)
+
+	return buf, true
+}
+
+func (gm GenericMessage) ParseSwapMessage() (SwapMessage, bool) {
+	if gm.Type != MSG_SWAP {
+		return SwapMessage{}, false
+	}
+
+	if len(gm.Args) > 0 {
+		return SwapMessage{}, false
+	}
+
+	return SwapMessage{}, true
+}
@@ -0,0 +1,530 @@
+// Code generated by msgs/gen from msgs/schema.json. DO NOT EDIT.
+
+package msgs
+
+import (
+	"online-game/types"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTripConnectedMessage(t *testing.T) {
+	want := ConnectedMessage{
+		ID:       -1000,
+		Version:  1001,
+		Features: 3,
+		Token:    "sample Token",
+		Username: "sample Username",
+	}
+
+	got, ok := roundTrip(t, want).ParseConnectedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripHostMessage(t *testing.T) {
+	want := HostMessage{}
+
+	got, ok := roundTrip(t, want).ParseHostMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripHostedMessage(t *testing.T) {
+	want := HostedMessage{
+		Room: "ZZZZ",
+	}
+
+	got, ok := roundTrip(t, want).ParseHostedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripJoinMessage(t *testing.T) {
+	want := JoinMessage{
+		Room: "ZZZZ",
+	}
+
+	got, ok := roundTrip(t, want).ParseJoinMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripJoinedMessage(t *testing.T) {
+	want := JoinedMessage{
+		Room: "ZZZZ",
+	}
+
+	got, ok := roundTrip(t, want).ParseJoinedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripLeaveMessage(t *testing.T) {
+	want := LeaveMessage{}
+
+	got, ok := roundTrip(t, want).ParseLeaveMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripLeftMessage(t *testing.T) {
+	want := LeftMessage{}
+
+	got, ok := roundTrip(t, want).ParseLeftMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripStartMessage(t *testing.T) {
+	want := StartMessage{}
+
+	got, ok := roundTrip(t, want).ParseStartMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripStartedMessage(t *testing.T) {
+	want := StartedMessage{}
+
+	got, ok := roundTrip(t, want).ParseStartedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripTeamMessage(t *testing.T) {
+	want := TeamMessage{}
+
+	got, ok := roundTrip(t, want).ParseTeamMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripTeamedMessage(t *testing.T) {
+	want := TeamedMessage{}
+
+	got, ok := roundTrip(t, want).ParseTeamedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripMoveMessage(t *testing.T) {
+	want := MoveMessage{
+		Seq:   3000000000,
+		Up:    true,
+		Down:  false,
+		Left:  true,
+		Right: false,
+	}
+
+	got, ok := roundTrip(t, want).ParseMoveMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripMovedMessage(t *testing.T) {
+	want := MovedMessage{}
+
+	got, ok := roundTrip(t, want).ParseMovedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripShootMessage(t *testing.T) {
+	want := ShootMessage{}
+
+	got, ok := roundTrip(t, want).ParseShootMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripShotMessage(t *testing.T) {
+	want := ShotMessage{
+		X:     -100000,
+		Y:     -100001,
+		State: types.Tile(3),
+	}
+
+	got, ok := roundTrip(t, want).ParseShotMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripChatMessage(t *testing.T) {
+	want := ChatMessage{
+		Message: "sample Message",
+	}
+
+	got, ok := roundTrip(t, want).ParseChatMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripChattedMessage(t *testing.T) {
+	want := ChattedMessage{
+		From:    -1000,
+		Message: "sample Message",
+	}
+
+	got, ok := roundTrip(t, want).ParseChattedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripSystemMessage(t *testing.T) {
+	want := SystemMessage{
+		Type:    1,
+		Message: "sample Message",
+	}
+
+	got, ok := roundTrip(t, want).ParseSystemMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripErrorMessage(t *testing.T) {
+	want := ErrorMessage{
+		Message: "sample Message",
+	}
+
+	got, ok := roundTrip(t, want).ParseErrorMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripWeaponPressedMessage(t *testing.T) {
+	want := WeaponPressedMessage{
+		WeaponId: types.WeaponId(1),
+		PlayerId: -1001,
+		Args:     []byte{1, 2, 2},
+	}
+
+	got, ok := roundTrip(t, want).ParseWeaponPressedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripWeaponUpdatedMessage(t *testing.T) {
+	want := WeaponUpdatedMessage{
+		WeaponId: types.WeaponId(1),
+		PlayerId: -1001,
+		Args:     []byte{1, 2, 2},
+	}
+
+	got, ok := roundTrip(t, want).ParseWeaponUpdatedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripWeaponReleasedMessage(t *testing.T) {
+	want := WeaponReleasedMessage{
+		WeaponId: types.WeaponId(1),
+		PlayerId: -1001,
+		Args:     []byte{1, 2, 2},
+	}
+
+	got, ok := roundTrip(t, want).ParseWeaponReleasedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripStateAckMessage(t *testing.T) {
+	want := StateAckMessage{
+		Seq: 3000000000,
+	}
+
+	got, ok := roundTrip(t, want).ParseStateAckMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripTilesMessage(t *testing.T) {
+	want := TilesMessage{
+		Checksum: 3000000000,
+		Tiles: []types.TileChange{
+			{X: int(1000), Y: int(1001), Tile: types.Tile(3)},
+			{X: int(1003), Y: int(1004), Tile: types.Tile(6)},
+		},
+	}
+
+	got, ok := roundTrip(t, want).ParseTilesMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripMapMismatchMessage(t *testing.T) {
+	want := MapMismatchMessage{}
+
+	got, ok := roundTrip(t, want).ParseMapMismatchMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripHelloMessage(t *testing.T) {
+	want := HelloMessage{
+		Version:  1000,
+		Features: 2,
+	}
+
+	got, ok := roundTrip(t, want).ParseHelloMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripResumeMessage(t *testing.T) {
+	want := ResumeMessage{
+		Token: "sample Token",
+	}
+
+	got, ok := roundTrip(t, want).ParseResumeMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripConfigureMessage(t *testing.T) {
+	want := ConfigureMessage{
+		Duration:     1000,
+		MaxPlayers:   2,
+		PlayerSpeed:  3,
+		MapWidth:     4,
+		MapHeight:    5,
+		MapDivisions: 6,
+		Weapons:      7,
+		Seed:         3000000007,
+		Map:          9,
+		Symmetry:     10,
+		Generator:    11,
+	}
+
+	got, ok := roundTrip(t, want).ParseConfigureMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripConfiguredMessage(t *testing.T) {
+	want := ConfiguredMessage{
+		Duration:     1000,
+		MaxPlayers:   2,
+		PlayerSpeed:  3,
+		MapWidth:     4,
+		MapHeight:    5,
+		MapDivisions: 6,
+		Weapons:      7,
+		Seed:         3000000007,
+		Map:          9,
+		Symmetry:     10,
+		Generator:    11,
+	}
+
+	got, ok := roundTrip(t, want).ParseConfiguredMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripArmMessage(t *testing.T) {
+	want := ArmMessage{
+		Slot:     types.Slot(1),
+		WeaponId: types.WeaponId(2),
+	}
+
+	got, ok := roundTrip(t, want).ParseArmMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripEntitySpawnedMessage(t *testing.T) {
+	want := EntitySpawnedMessage{
+		EntityId: 1000,
+		Kind:     types.EntityKind(2),
+		Team:     types.TeamID(3),
+		X:        3.25,
+		Y:        4.25,
+		Args:     []byte{1, 2, 5},
+	}
+
+	got, ok := roundTrip(t, want).ParseEntitySpawnedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripEntityDespawnedMessage(t *testing.T) {
+	want := EntityDespawnedMessage{
+		EntityId: 1000,
+	}
+
+	got, ok := roundTrip(t, want).ParseEntityDespawnedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripEntityTriggeredMessage(t *testing.T) {
+	want := EntityTriggeredMessage{
+		EntityId: 1000,
+		X:        1.25,
+		Y:        2.25,
+	}
+
+	got, ok := roundTrip(t, want).ParseEntityTriggeredMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripWeaponRejectedMessage(t *testing.T) {
+	want := WeaponRejectedMessage{
+		Slot:     types.Slot(1),
+		WeaponId: types.WeaponId(2),
+		Reason:   "sample Reason",
+	}
+
+	got, ok := roundTrip(t, want).ParseWeaponRejectedMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTripSwapMessage(t *testing.T) {
+	want := SwapMessage{}
+
+	got, ok := roundTrip(t, want).ParseSwapMessage()
+	if !ok {
+		t.Fatal("could not parse the encoded message")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
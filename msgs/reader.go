@@ -0,0 +1,86 @@
+package msgs
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// reader decodes little endian values from a message's arguments. Reading
+// past the end yields zero values and clears ok instead of panicking.
+type reader struct {
+	buf []byte
+	ok  bool
+}
+
+func newReader(buf []byte) *reader {
+	return &reader{buf: buf, ok: true}
+}
+
+// bytes reads the next n bytes
+func (r *reader) bytes(n int) []byte {
+	if !r.ok || n > len(r.buf) {
+		r.ok = false
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// rest reads everything that is left
+func (r *reader) rest() []byte {
+	return r.bytes(len(r.buf))
+}
+
+// done reports whether the whole message was read without errors
+func (r *reader) done() bool {
+	return r.ok && len(r.buf) == 0
+}
+
+func (r *reader) u8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) i8() int8 {
+	return int8(r.u8())
+}
+
+func (r *reader) bool() bool {
+	return r.u8() != 0
+}
+
+func (r *reader) u16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) i16() int16 {
+	return int16(r.u16())
+}
+
+func (r *reader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) i32() int32 {
+	return int32(r.u32())
+}
+
+func (r *reader) f64() float64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
@@ -1,35 +1,36 @@
-// messages
-const MESSAGES = {};
-
-MESSAGES[MESSAGES["MSG_CNCT"] = 0] = "MSG_CNCT";
-MESSAGES[MESSAGES["MSG_HOST"] = 1] = "MSG_HOST";
-MESSAGES[MESSAGES["MSG_HOSTED"] = 2] = "MSG_HOSTED";
-MESSAGES[MESSAGES["MSG_JOIN"] = 3] = "MSG_JOIN";
-MESSAGES[MESSAGES["MSG_JOINED"] = 4] = "MSG_JOINED";
-MESSAGES[MESSAGES["MSG_LEAVE"] = 5] = "MSG_LEAVE";
-MESSAGES[MESSAGES["MSG_LEFT"] = 6] = "MSG_LEFT";
-MESSAGES[MESSAGES["MSG_START"] = 7] = "MSG_START";
-MESSAGES[MESSAGES["MSG_STARTED"] = 8] = "MSG_STARTED";
-MESSAGES[MESSAGES["MSG_TEAM"] = 9] = "MSG_TEAM";
-MESSAGES[MESSAGES["MSG_TEAMED"] = 10] = "MSG_TEAMED";
-MESSAGES[MESSAGES["MSG_MOVE"] = 11] = "MSG_MOVE";
-MESSAGES[MESSAGES["MSG_MOVED"] = 12] = "MSG_MOVED";
-MESSAGES[MESSAGES["MSG_SHOOT"] = 13] = "MSG_SHOOT";
-MESSAGES[MESSAGES["MSG_SHOT"] = 14] = "MSG_SHOT";
-MESSAGES[MESSAGES["MSG_CHAT"] = 15] = "MSG_CHAT";
-MESSAGES[MESSAGES["MSG_CHATTED"] = 16] = "MSG_CHATTED";
-MESSAGES[MESSAGES["MSG_MAP"] = 17] = "MSG_MAP";
-MESSAGES[MESSAGES["MSG_STATE"] = 18] = "MSG_STATE";
-MESSAGES[MESSAGES["MSG_SYSTEM"] = 19] = "MSG_SYSTEM";
-MESSAGES[MESSAGES["MSG_ERROR"] = 20] = "MSG_ERROR";
-MESSAGES[MESSAGES["MSG_WEAPONDOWN"] = 21] = "MSG_WEAPONDOWN";
-MESSAGES[MESSAGES["MSG_WEAPONUPDATE"] = 22] = "MSG_WEAPONUPDATE";
-MESSAGES[MESSAGES["MSG_WEAPONUP"] = 23] = "MSG_WEAPONUP";
-MESSAGES[MESSAGES["MSG_WEAPONPRESSED"] = 24] = "MSG_WEAPONPRESSED";
-MESSAGES[MESSAGES["MSG_WEAPONUPDATED"] = 25] = "MSG_WEAPONUPDATED";
-MESSAGES[MESSAGES["MSG_WEAPONRELEASED"] = 26] = "MSG_WEAPONRELEASED";
-MESSAGES[MESSAGES["MSG_LEN"] = 27] = "MSG_LEN";
+// messages are declared in messages_gen.js, generated from msgs/schema.json
 
+// protocol, must match msgs.PROTOCOL_VERSION on the server
+const PROTOCOL_VERSION = 14;
+const FEATURE_COMPRESSION = 1 << 0;
+const FEATURE_DELTA_STATE = 1 << 1;
+const FEATURE_JSON_DEBUG = 1 << 2;
+
+// state deltas
+const DELTA_STATE_HOST = 1 << 0;
+const DELTA_STATE_STARTED = 1 << 1;
+const DELTA_STATE_TEAMS = 1 << 2;
+const DELTA_STATE_SCORES = 1 << 3;
+const DELTA_STATE_PHASE = 1 << 4;
+
+const DELTA_PLAYER_TEAM = 1 << 0;
+const DELTA_PLAYER_X = 1 << 1;
+const DELTA_PLAYER_Y = 1 << 2;
+const DELTA_PLAYER_VX = 1 << 3;
+const DELTA_PLAYER_VY = 1 << 4;
+const DELTA_PLAYER_WEAPON = 1 << 5;
+const DELTA_PLAYER_JOINED = 1 << 6;
+const DELTA_PLAYER_INPUT = 1 << 7;
+const DELTA_PLAYER_INK = 1 << 8;
+const DELTA_PLAYER_ALL = (1 << 9) - 1;
+
+// weapon slots of a player, must match types.LoadoutSize on the server
+const LOADOUT_SIZE = 2;
+
+const POSITION_SCALE = 256;
+// must cover the server's StateHistory so any acknowledged baseline is still here
+const STATE_HISTORY = 64;
+const stateSnapshots = new Map();
 
 // system messages
 const SYSTEM_MESSAGES = {};
@@ -48,6 +49,24 @@ function getBoolean(data, state) {
     return boolean
 }
 
+function getInt8(data, state) {
+    const int8 = data.getInt8(state.i);
+    state.i += 1;
+    return int8
+}
+
+function getUint16(data, state) {
+    const uint16 = data.getUint16(state.i, true);
+    state.i += 2;
+    return uint16
+}
+
+function getUint32(data, state) {
+    const uint32 = data.getUint32(state.i, true);
+    state.i += 4;
+    return uint32
+}
+
 function getInt16(data, state) {
     const int16 = data.getInt16(state.i, true);
     state.i += 2;
@@ -66,12 +85,189 @@ function getFloat64(data, state) {
     return float64
 }
 
+function getBytes(data, length, state) {
+    const bytes = new Uint8Array(data.buffer.slice(state.i + data.byteOffset, state.i + length + data.byteOffset));
+    state.i += length;
+    return bytes
+}
+
 function getString(data, length, state) {
     const string = new TextDecoder("utf-8").decode(data.buffer.slice(state.i + data.byteOffset, state.i + length + data.byteOffset));
     state.i += length;
     return string
 }
 
+// encode helpers
+
+class MsgWriter {
+    buf = [];
+
+    _set(size, set) {
+        const view = new DataView(new ArrayBuffer(size));
+        set(view);
+        this.buf.push(...new Uint8Array(view.buffer));
+    }
+
+    Int8(v) { this._set(1, view => view.setInt8(0, v)); }
+    Uint8(v) { this._set(1, view => view.setUint8(0, v)); }
+    Boolean(v) { this.Uint8(v ? 1 : 0); }
+    Int16(v) { this._set(2, view => view.setInt16(0, v, true)); }
+    Uint16(v) { this._set(2, view => view.setUint16(0, v, true)); }
+    Int32(v) { this._set(4, view => view.setInt32(0, v, true)); }
+    Uint32(v) { this._set(4, view => view.setUint32(0, v, true)); }
+    Float64(v) { this._set(8, view => view.setFloat64(0, v, true)); }
+    raw(bytes) { this.buf.push(...bytes); }
+
+    bytes() {
+        return new Uint8Array(this.buf);
+    }
+}
+
+// map
+
+/**
+ * FNV-1a hash of the map tiles, must match entities.Checksum on the server
+ * @param {{tiles: number[]}} map
+ */
+function mapChecksum(map) {
+    let hash = 0x811c9dc5;
+    for (const tile of map.tiles) {
+        hash ^= tile;
+        hash = Math.imul(hash, 0x01000193);
+    }
+    return hash >>> 0;
+}
+
+// state snapshots
+
+function decodeStartedAt(unix) {
+    return unix <= 0 ? null : new Date(unix * 1000);
+}
+
+/**
+ * reads the player fields selected by mask into player
+ */
+function decodePlayerFields(view, state, player, mask) {
+    if (mask & DELTA_PLAYER_TEAM) player.team = getUint8(view, state);
+    if (mask & DELTA_PLAYER_X) player.x = getUint16(view, state) / POSITION_SCALE;
+    if (mask & DELTA_PLAYER_Y) player.y = getUint16(view, state) / POSITION_SCALE;
+    if (mask & DELTA_PLAYER_VX) player.vx = getInt8(view, state);
+    if (mask & DELTA_PLAYER_VY) player.vy = getInt8(view, state);
+    if (mask & DELTA_PLAYER_WEAPON) {
+        player.active = getUint8(view, state);
+        player.loadout = [];
+        for (let slot = 0; slot < LOADOUT_SIZE; slot++) {
+            player.loadout.push({
+                weaponId: getUint8(view, state),
+                status: {
+                    cooldown: getUint16(view, state) / 1000, // seconds left
+                    charge: getUint8(view, state) / 255,
+                    ammo: getUint8(view, state) / 255,
+                },
+            });
+        }
+        // the weapon in hand
+        player.weaponId = player.loadout[player.active].weaponId;
+        player.weaponStatus = player.loadout[player.active].status;
+    }
+    if (mask & DELTA_PLAYER_JOINED) {
+        const usernameLen = getUint8(view, state);
+        player.user.username = getString(view, usernameLen, state);
+    }
+    if (mask & DELTA_PLAYER_INPUT) player.inputSeq = getUint32(view, state);
+    if (mask & DELTA_PLAYER_INK) player.ink = getUint8(view, state) / 100;
+}
+
+/**
+ * keeps a decoded snapshot around as a baseline for later deltas and
+ * attaches the players' weapon objects, one per loadout slot
+ */
+function storeSnapshot(data) {
+    data.players.forEach((player, i) => {
+        let previous = [];
+        try {
+            previous = game.state.players.find(p => p.user.id === player.user.id).weapons || [];
+        } catch (err) {}
+        player.weapons = player.loadout.map(({ weaponId }, slot) => {
+            let weapon = previous[slot];
+            if (!weapon || weapon.id !== weaponId) {
+                weapon = Weapon.getWeaponObject(weaponId, i);
+            }
+            if (weapon) weapon.player_index = i;
+            return weapon;
+        });
+        player.weapon = player.weapons[player.active];
+    });
+
+    // the game loop moves players locally, so baselines must be copies
+    stateSnapshots.set(data.seq, {
+        ...data,
+        state: { ...data.state },
+        players: data.players.map(p => ({ ...p, user: { ...p.user } })),
+    });
+    for (const seq of stateSnapshots.keys()) {
+        if (seq + STATE_HISTORY <= data.seq) {
+            stateSnapshots.delete(seq);
+        }
+    }
+}
+
+function decodeStateDelta(view, state) {
+    const data = {};
+    data.seq = getUint32(view, state);
+    const baseSeq = getUint32(view, state);
+    const base = stateSnapshots.get(baseSeq);
+    if (!base) {
+        throw new Error("Missing state baseline " + baseSeq);
+    }
+
+    data.host = base.host;
+    data.room = base.room;
+    data.started = base.started;
+    data.startedAt = base.startedAt;
+    data.state = { ...base.state };
+
+    const mask = getUint8(view, state);
+    if (mask & DELTA_STATE_HOST) data.host = getInt16(view, state);
+    if (mask & DELTA_STATE_STARTED) {
+        data.started = getBoolean(view, state);
+        data.startedAt = decodeStartedAt(getInt32(view, state));
+    }
+    if (mask & DELTA_STATE_TEAMS) {
+        data.state.teamA = getInt32(view, state);
+        data.state.teamB = getInt32(view, state);
+    }
+    if (mask & DELTA_STATE_SCORES) {
+        data.state.scoreA = getInt32(view, state);
+        data.state.scoreB = getInt32(view, state);
+    }
+    if (mask & DELTA_STATE_PHASE) data.state.phase = getUint8(view, state);
+
+    const removed = new Set();
+    const removedLen = getUint8(view, state);
+    for (let i = 0; i < removedLen; i++) {
+        removed.add(getInt16(view, state));
+    }
+
+    data.players = base.players
+        .filter(p => !removed.has(p.user.id))
+        .map(p => ({ ...p, user: { ...p.user } }));
+
+    const changedLen = getUint8(view, state);
+    for (let i = 0; i < changedLen; i++) {
+        const id = getInt16(view, state);
+        const playerMask = getUint16(view, state);
+        let player = data.players.find(p => p.user.id === id);
+        if (!player) {
+            player = { user: { id } };
+            data.players.push(player);
+        }
+        decodePlayerFields(view, state, player, playerMask);
+    }
+
+    return data;
+}
+
 // decode
 
 /**
@@ -80,53 +276,26 @@ function getString(data, length, state) {
  */
 function decodeMsg(msg) {
     const type = new Uint8Array(msg, 0)[0];   
-    if (!type in MESSAGES) {
+    if (!(type in MESSAGES)) {
         return null;
     }
 
     const view = new DataView(msg, 1);
     const state = { i: 0 };
-    const data = {};
+    let data = {};
 
     switch (MESSAGES[type]) {
-        case "MSG_CNCT":
-            data.id = view.getInt16(0, true);
-            data.username = new TextDecoder("utf-8").decode(msg.slice(3));
-            break;
-        case "MSG_HOSTED":
-            data.room = new TextDecoder("utf-8").decode(msg.slice(1));
-            break;
-        case "MSG_JOINED":
-            data.room = getString(view, 4, state);
-            break;
-        case "MSG_LEFT":
-            break;
-        case "MSG_SHOT":
-            data.x = getInt32(view, state);
-            data.y = getInt32(view, state);
-            data.state = getUint8(view, state);
-            break;
-        case "MSG_CHATTED":
-            data.from = getInt16(view, state);
-            const sz = getUint8(view, state);
-            data.message = getString(view, sz, state);
-            break;
         case "MSG_MAP":
             data.width = getInt32(view, state);
             data.height = getInt32(view, state);
             data.tiles = Array.from(new Uint8Array(view.buffer, state.i + view.byteOffset, data.width * data.height));
             break;
         case "MSG_STATE": {
+            data.seq = getUint32(view, state);
             data.host = getInt16(view, state);
             data.room = getString(view, 4, state);
             data.started = getBoolean(view, state);
-
-            const unix = getInt32(view, state);
-            if (unix <= 0) {
-                data.startedAt = null;
-            } else {
-                data.startedAt = new Date(unix * 1000);
-            }
+            data.startedAt = decodeStartedAt(getInt32(view, state));
 
             data.state = {};
             data.state.teamA = getInt32(view, state);
@@ -138,44 +307,16 @@ function decodeMsg(msg) {
             const playersLen = getUint8(view, state);
             data.players = [];
             for (let i = 0; i < playersLen; i++) {
-                var weapon=undefined;
-                try{
-                    weapon = game.state.players[i].weapon;
-                }catch(err){}
-                
-                data.players.push({
-                    user: {
-                        id: getInt16(view, state),
-                    },
-                    team: getUint8(view, state),
-                    x: getFloat64(view, state),
-                    y: getFloat64(view, state),
-                    vx: getInt32(view, state),
-                    vy: getInt32(view, state),
-                    weapon: weapon?? Weapon.getWeaponObject(getUint8(view, state),i)
-                });
-                //if the wepon is not null read the weponId byte to skip it
-                if(weapon)getUint8(view, state);
-
-                const usernameLen = getUint8(view, state);
-                data.players[i].user.username = getString(view, usernameLen, state);
+                const player = { user: { id: getInt16(view, state) } };
+                decodePlayerFields(view, state, player, DELTA_PLAYER_ALL);
+                data.players.push(player);
             }
+            storeSnapshot(data);
+        } break;
+        case "MSG_STATEDELTA": {
+            Object.assign(data, decodeStateDelta(view, state));
+            storeSnapshot(data);
         } break;
-        case "MSG_SYSTEM":
-            const sysType = getUint8(view, state);
-            if (sysType in SYSTEM_MESSAGES) {
-                data.type = SYSTEM_MESSAGES[sysType];
-            } else {
-                throw new Error("Unknown System Message " + sysType);
-            }
-
-            const messageLen = getUint8(view, state);
-            data.message = getString(view, messageLen, state);
-            break;
-        case "MSG_ERROR":
-            const size = getUint8(view, state);
-            data.message = getString(view, size, state);
-            break;
         case "MSG_WEAPONPRESSED":
             data.data=Weapon.decodeWeaponPressedMSG(msg);
             break;
@@ -185,16 +326,18 @@ function decodeMsg(msg) {
         case "MSG_WEAPONRELEASED":
             data.data=Weapon.decodeWeaponReleasedMSG(msg);
             break;
+        default:
+            data = decodeGeneratedMsg(MESSAGES[type], view);
+            if (!data) {
+                throw new Error("Not Recivable " + MESSAGES[type]);
+            }
+    }
 
-        case "MSG_HOST":
-        case "MSG_JOIN":
-        case "MSG_LEAVE":
-        case "MSG_START":
-        case "MSG_TEAM":
-        case "MSG_MOVE":
-        case "MSG_SHOOT":
-        case "MSG_CHAT":
-            throw new Error("Not Recivable " + MESSAGES[type]);
+    if (MESSAGES[type] === "MSG_SYSTEM") {
+        if (!(data.type in SYSTEM_MESSAGES)) {
+            throw new Error("Unknown System Message " + data.type);
+        }
+        data.type = SYSTEM_MESSAGES[data.type];
     }
 
     return {
@@ -211,106 +354,30 @@ function decodeMsg(msg) {
  */
 function encodeMsg(msg) {
     const type = MESSAGES[msg.type];
-    if (!type) {
+    if (type === undefined) {
         throw new Error("Unknown message type", msg.type)
     }
 
     let buf = null;
 
     switch (msg.type) {
-        case "MSG_CNCT":
-        case "MSG_HOSTED":
-        case "MSG_JOINED":
-        case "MSG_LEFT":
-        case "MSG_STARTED":
-        case "MSG_SHOT":
-        case "MSG_CHATTED":
-        case "MSG_MAP":
-        case "MSG_STATE":
-        case "MSG_SYSTEM":
-        case "MSG_ERROR":
-            throw new Error("Not Sendable " + msg.type);
-        case "MSG_HOST":
-            buf = new Uint8Array(1);
-            buf[0] = type;
-            break;
-        case "MSG_JOIN":
-            buf = new Uint8Array(5);
-            buf[0] = type;
-            const { room } = msg.data;
-            if (room.length > 4) {
-                return { error: "Room name too long" };
-            }
-            const roomBuf = new TextEncoder("utf-8").encode(room);
-            buf.set(roomBuf, 1);
-            break;
-        case "MSG_LEAVE":
-        case "MSG_START":
-        case "MSG_TEAM":
-        case "MSG_SHOOT":
-            buf = new Uint8Array(1);
-            buf[0] = type;
-            break;
-        case "MSG_MOVE":
-            const { direction, start } = msg.data;
-            buf = new Uint8Array(2);
-            buf[0] = type;
-            let flags = 0;
-            switch (direction) {
-                case "up": flags |= 1 << 0; break;
-                case "down": flags |= 1 << 1; break;
-                case "left": flags |= 1 << 2; break;
-                case "right": flags |= 1 << 3; break;
-            }
-            if (start) {
-                flags |= 1 << 4;
-            }
-            buf[1] = flags;
-            break;
-        case "MSG_CHAT":
-            const sz = msg.data.message.length;
-            if (sz > 255) {
-                return { error: "Message too long" };
-            }
-            buf = new Uint8Array(sz + 1 + 1); // for type and size
-            buf[0] = type;
-            buf[1] = sz;
-            const msgBuf = new TextEncoder("utf-8").encode(msg.data.message);
-            buf.set(msgBuf, 2);
-            break;
         case "MSG_WEAPONDOWN":
-            buffer = new DataView(new ArrayBuffer(10));
-            // Combine type, weapon_id, and seta byte arrays
-            buffer.setUint8(0,type);
-            buffer.setUint8(1,WEAPONS["WEAPON_Grenade"]);
-            buffer.setFloat64(2,msg.data.seta, true);
-            
-            // Concatenate the byte arrays
-            buf = new Uint8Array(buffer.buffer);
-            break;
         case "MSG_WEAPONUPDATE":
-            buffer = new DataView(new ArrayBuffer(10));
-            // Combine type, weapon_id, and seta byte arrays
-            buffer.setUint8(0,type);
-            buffer.setUint8(1,WEAPONS["WEAPON_Grenade"]);
-            buffer.setFloat64(2,msg.data.seta, true);
-            
-            // Concatenate the byte arrays
-            buf = new Uint8Array(buffer.buffer);
-            break;
-        case "MSG_WEAPONUP":
-            buffer = new DataView(new ArrayBuffer(18));
-            // Combine x and y byte arrays
-            buffer.setUint8(0,type);
-            buffer.setUint8(1,WEAPONS["WEAPON_Grenade"]);
-            buffer.setFloat64(2,msg.data.x, true);
-            buffer.setFloat64(10,msg.data.y, true);
-            
-            // Concatenate the byte arrays
-            buf = new Uint8Array(buffer.buffer);
-            break;
-        
+        case "MSG_WEAPONUP": {
+            // the weapon encodes its own arguments, see Weapon.downArgs
+            const w = new MsgWriter();
+            w.Uint8(type);
+            w.Uint8(msg.data.slot);
+            w.Uint8(msg.data.weaponId);
+            w.raw(msg.data.args);
+            buf = w.bytes();
+        } break;
+        default:
+            buf = encodeGeneratedMsg(msg);
+            if (!buf) {
+                throw new Error("Not Sendable " + msg.type);
+            }
     }
 
     return buf;
-}
\ No newline at end of file
+}
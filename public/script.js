@@ -1,14 +1,21 @@
-const game = { state: null, ctx: null, map: null };
+const game = { state: null, ctx: null, map: null, settings: null, entities: new Map(), splashes: [] };
 let activeScreen = 0; // 0: Home, 1: Game
 let lastTimestamp = 0;
 const myData = {
     id: null,
     username: null,
+    protocol: null,
 };
 let isServerUpdated = false;
 
 let rendering = false;
 let amAiming=false
+// whether the server rejected the weapon message since the mouse went down
+let weaponRejected=false
+let lastAimAt=0
+// least time between two aim updates in ms, the server drops faster ones
+const AIM_INTERVAL = 60;
+const SLOT_NAMES = ["Primary", "Secondary"];
 let mouseLocation = {x:0,y:0};
 let myLocation = { x: 0, y: 0 }; //the player coordinates from the topLeft of the canvas in ~pixels;
 let targetLocation = { x: 0, y: 0 }; //the target coordinates from the topLeft of the canvas in ~pixels;
@@ -18,8 +25,25 @@ const rang_constB = 2
 const max_cell_range = 5
 
 // CONSTANTS
-const playerSpeed = 10;
-const gameDuration = 60 * 1000; // 1 minute
+// movement input, numbered so the state can tell which one the server applied last
+const myInput = { seq: 0, up: false, down: false, left: false, right: false };
+const RECONCILE_SNAP = 1; // tiles, larger prediction errors are not smoothed
+const RECONCILE_BLEND = 0.2; // share of the error corrected per state
+const RECONNECT_DELAY = 1000; // ms
+// match settings the host can edit, as in MSG_CONFIGURE
+const SETTINGS_FIELDS = [
+    { key: "duration", label: "Duration (s)" },
+    { key: "maxPlayers", label: "Max players" },
+    { key: "playerSpeed", label: "Player speed" },
+    { key: "mapWidth", label: "Map width" },
+    { key: "mapHeight", label: "Map height" },
+    { key: "mapDivisions", label: "Map divisions" },
+    { key: "seed", label: "Map seed" },
+];
+// how a generated map mirrors its halves, by MatchSettings.Symmetry
+const SYMMETRIES = ["None", "Left and right", "Top and bottom", "Rotational"];
+// how generated maps are laid out, by MatchSettings.Generator
+const GENERATORS = ["Rooms", "Caves", "Maze"];
 // Game States
 const WaitingForPlayers = 0;
 const Playing = 1;
@@ -29,6 +53,11 @@ const EmptyTile = 0;
 const TeamATile = 1;
 const TeamBTile = 2;
 const WallTile = 3;
+// Entity Kinds
+const MineEntity = 0;
+const GrenadeEntity = 1;
+// how long an entity going off stays on screen, in ms
+const SPLASH_DURATION = 400;
 
 function HomeScreen(root, handlers) {
     activeScreen = 0;
@@ -120,6 +149,84 @@ function GameScreen(root, handlers) {
     leaveBtn.textContent = "Leave Room";
     titleRow.appendChild(leaveBtn);
 
+    const weaponSelects = SLOT_NAMES.map((name, slot) => {
+        const weaponRow = document.createElement("label");
+        weaponRow.classList.add("row", "between");
+        weaponRow.textContent = name;
+        chat.appendChild(weaponRow);
+
+        const weaponSelect = document.createElement("select");
+        weaponSelect.classList.add("weapon");
+        weaponSelect.dataset.slot = slot;
+        weaponRow.appendChild(weaponSelect);
+        return weaponSelect;
+    });
+
+    const settings = document.createElement("details");
+    settings.id = "settings";
+    settings.classList.add("settings");
+    chat.appendChild(settings);
+
+    const settingsTitle = document.createElement("summary");
+    settingsTitle.textContent = "Match settings";
+    settings.appendChild(settingsTitle);
+
+    for (const { key, label } of SETTINGS_FIELDS) {
+        const row = document.createElement("label");
+        row.textContent = label;
+        settings.appendChild(row);
+
+        const input = document.createElement("input");
+        input.type = "number";
+        input.dataset.setting = key;
+        row.appendChild(input);
+    }
+
+    const mapRow = document.createElement("label");
+    mapRow.textContent = "Map";
+    settings.appendChild(mapRow);
+
+    const mapSelect = document.createElement("select");
+    mapSelect.dataset.setting = "map";
+    mapSelect.appendChild(new Option("Generated", 0));
+    mapRow.appendChild(mapSelect);
+    loadMapPool(mapSelect);
+
+    const symmetryRow = document.createElement("label");
+    symmetryRow.textContent = "Symmetry";
+    settings.appendChild(symmetryRow);
+
+    const symmetrySelect = document.createElement("select");
+    symmetrySelect.dataset.setting = "symmetry";
+    SYMMETRIES.forEach((name, i) => symmetrySelect.appendChild(new Option(name, i)));
+    symmetryRow.appendChild(symmetrySelect);
+
+    const generatorRow = document.createElement("label");
+    generatorRow.textContent = "Generator";
+    settings.appendChild(generatorRow);
+
+    const generatorSelect = document.createElement("select");
+    generatorSelect.dataset.setting = "generator";
+    GENERATORS.forEach((name, i) => generatorSelect.appendChild(new Option(name, i)));
+    generatorRow.appendChild(generatorSelect);
+
+    for (const id of weaponIds()) {
+        const row = document.createElement("label");
+        row.textContent = weaponName(id);
+        settings.appendChild(row);
+
+        const input = document.createElement("input");
+        input.type = "checkbox";
+        input.dataset.weapon = id;
+        row.appendChild(input);
+    }
+
+    const applyBtn = document.createElement("button");
+    applyBtn.type = "button";
+    applyBtn.classList.add("btn");
+    applyBtn.textContent = "Apply";
+    settings.appendChild(applyBtn);
+
     const chatBoxContainer = document.createElement("div");
     chatBoxContainer.classList.add("chat-box-container");
     chat.appendChild(chatBoxContainer);
@@ -161,6 +268,17 @@ function GameScreen(root, handlers) {
         handlers.leaveRoom();
     });
 
+    applyBtn.addEventListener("click", () => {
+        handlers.configure(settings);
+    });
+
+    for (const weaponSelect of weaponSelects) {
+        weaponSelect.addEventListener("change", () => {
+            handlers.arm(weaponSelect);
+        });
+    }
+    refreshSettings();
+
     appendSystemMessage("SYS_MSG_INFO", "Welcome to the game");
     appendSystemMessage("SYS_MSG_SUCCESS", "Your username is " + myData.username);
     appendSystemMessage("SYS_MSG_INFO", "Use arrow keys to move");
@@ -193,8 +311,9 @@ function tick(ts) {
             one = false;
         }
         for (const player of gameState.players) {
-            let newX = player.x + player.vx * dt * playerSpeed;
-            let newY = player.y + player.vy * dt * playerSpeed;
+            const speed = game.settings.playerSpeed * (player.weapon ? player.weapon.speedFactor() : 1);
+            let newX = player.x + player.vx * dt * speed;
+            let newY = player.y + player.vy * dt * speed;
 
             const { tile, bottom, right, bottomRight } = getAroundMap(map, Math.floor(newX), Math.floor(newY));
             const cornerX = newX - Math.floor(newX) > 0;
@@ -230,6 +349,11 @@ function tick(ts) {
     } else {
         isServerUpdated = false;
     }
+    if (gameState.started) {
+        for (const entity of game.entities.values()) {
+            updateEntity(entity, dt, map);
+        }
+    }
 
     // Render
     const teamAColor = "#" + gameState.state.teamA.toString(16).padStart(6, "0");
@@ -249,7 +373,7 @@ function tick(ts) {
     if (gameState.started) {
         const started = new Date(gameState.startedAt);
         const now = new Date();
-        const left = gameDuration - (now - started);
+        const left = game.settings.duration * 1000 - (now - started);
         ctx.fillStyle = "#f0f0f0";
         if (left <= 0) {
             ctx.fillText("Time is up", wOffset + wRest / 2, hOffset / 2);
@@ -260,7 +384,9 @@ function tick(ts) {
         }
     } else {
         ctx.fillStyle = "#f0f0f0";
-        ctx.fillText("01:00", width / 2, hOffset / 2);
+        const minutes = Math.floor(game.settings.duration / 60).toString().padStart(2, "0");
+        const seconds = (game.settings.duration % 60).toString().padStart(2, "0");
+        ctx.fillText(`${minutes}:${seconds}`, width / 2, hOffset / 2);
     }
 
     // Sidebar
@@ -287,6 +413,12 @@ function tick(ts) {
     ctx.fillStyle = "#f0f0f0";
     ctx.fillText(`Team B (${teamB.length})`, wOffset / 2, sidebarCenter + 55 + squareSize, wOffset - 20);
 
+    // my weapon
+    const me = myPlayer(gameState);
+    if (gameState.started && me && me.weaponStatus) {
+        renderWeaponStatus(ctx, me, 10, height - 140, wOffset - 20);
+    }
+
     // Map
     ctx.fillStyle = "#FFD35A";
     ctx.fillRect(wOffset, hOffset, wRest, hRest);
@@ -324,6 +456,14 @@ function tick(ts) {
 
         // Render players
         if (gameState.state.phase === 1) {
+            for (const entity of game.entities.values()) {
+                renderEntity(ctx, entity, entity.team === 0 ? teamAColor : teamBColor);
+            }
+            const now = performance.now();
+            game.splashes = game.splashes.filter((splash) => splash.until > now);
+            for (const splash of game.splashes) {
+                renderSplash(ctx, splash, splash.team === 0 ? teamAColor : teamBColor, now);
+            }
             for (const player of gameState.players) {
                 const x = player.x + mapWidthOffset;
                 const y = player.y + mapHeightOffset;
@@ -365,6 +505,14 @@ function tick(ts) {
         switch (gameState.state.phase) {
             case WaitingForPlayers: {
                 ctx.fillText("Waiting for players", wOffset + wRest / 2, hOffset + hRest / 2);
+
+                // everyone's loadout, so teams can coordinate
+                ctx.font = "24px Arial";
+                gameState.players.forEach((player, i) => {
+                    const loadout = player.loadout.map(({ weaponId }) => weaponName(weaponId)).join(" / ");
+                    ctx.fillStyle = player.team === 0 ? teamAColor : teamBColor;
+                    ctx.fillText(`${player.user.username}: ${loadout}`, wOffset + wRest / 2, hOffset + hRest / 2 + 70 + i * 32);
+                });
             } break;
             case GameOver: {
                 const winner = gameState.state.scoreA > gameState.state.scoreB ? "Team A Wins"
@@ -442,78 +590,76 @@ function appendSystemMessage(type, message) {
 (() => {
     const root = document.getElementById("root");
 
-    let ws = new WebSocket("/ws");
-    ws.binaryType = "arraybuffer";
-    setupWSListeners(ws, {
-        joinRoom,
-        hostRoom,
-        leaveRoom,
-        startGame,
-        chat,
-        setupGameControls,
-    },
-        root
-    );
+    let ws;
+    function connect() {
+        ws = new WebSocket("/ws");
+        ws.binaryType = "arraybuffer";
+        setupWSListeners(ws, {
+            joinRoom,
+            hostRoom,
+            leaveRoom,
+            startGame,
+            chat,
+            configure,
+            arm,
+            setupGameControls,
+            reconnect,
+        },
+            root
+        );
+    }
+
+    function reconnect() {
+        setTimeout(connect, RECONNECT_DELAY);
+    }
+
+    connect();
+
+    function setHeld(direction, held) {
+        if (myInput[direction] === held) return;
+        myInput[direction] = held;
+        myInput.seq++;
+        const { seq, up, down, left, right } = myInput;
+        ws.send(
+            encodeMsg({
+                type: "MSG_MOVE",
+                data: { seq, up, down, left, right },
+            })
+        );
+
+        // predict, the server confirms it by echoing seq in the state
+        const me = myPlayer(game.state);
+        if (me && game.state.started) {
+            [me.vx, me.vy] = inputVelocity(myInput);
+        }
+    }
 
     function setupGameControls(canvas) {
+        // keyup never arrives once the canvas lost focus
+        canvas.addEventListener("blur", () => {
+            for (const direction of ["up", "down", "left", "right"]) {
+                setHeld(direction, false);
+            }
+        });
+
         canvas.addEventListener("keydown", (e) => {
             if (e.repeat) return;
             switch (e.code) {
                 case "KeyW":
                 case "ArrowUp":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "up",
-                                    start: true,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("up", true);
                     break;
                 case "KeyS":
                 case "ArrowDown":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "down",
-                                    start: true,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("down", true);
                     break;
                 case "KeyA":
                 case "ArrowLeft":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "left",
-                                    start: true,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("left", true);
                     break;
                 case "KeyD":
                 case "ArrowRight":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "right",
-                                    start: true,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("right", true);
                     break;
                 case "KeyQ":
                     {
@@ -543,6 +689,15 @@ function appendSystemMessage(type, message) {
                         );
                     }
                     break;
+                case "KeyE":
+                    {
+                        ws.send(
+                            encodeMsg({
+                                type: "MSG_SWAP",
+                            })
+                        );
+                    }
+                    break;
                 case "KeyR":
                     {
                         one = true;
@@ -556,59 +711,19 @@ function appendSystemMessage(type, message) {
             switch (e.code) {
                 case "KeyW":
                 case "ArrowUp":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "up",
-                                    start: false,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("up", false);
                     break;
                 case "KeyS":
                 case "ArrowDown":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "down",
-                                    start: false,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("down", false);
                     break;
                 case "KeyA":
                 case "ArrowLeft":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "left",
-                                    start: false,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("left", false);
                     break;
                 case "KeyD":
                 case "ArrowRight":
-                    {
-                        ws.send(
-                            encodeMsg({
-                                type: "MSG_MOVE",
-                                data: {
-                                    direction: "right",
-                                    start: false,
-                                },
-                            })
-                        );
-                    }
+                    setHeld("right", false);
                     break;
             }
         });
@@ -626,17 +741,22 @@ function appendSystemMessage(type, message) {
             mouseLocation={x:mouseX,y:mouseY}
 
             let targetInfo = getTargetInfo(mouseX,mouseY)
+
+            weaponRejected = false;
+            const weapon = myWeapon();
+            const args = weapon && weapon.downArgs(targetInfo);
+            if (!args) {
+                return;
+            }
             
-            amAiming=true;
+            amAiming=weapon.aims;
             startBuildingAt = startBuildingAt?? Date.now();
             targetLocation = { x: targetInfo.x, y: targetInfo.y };
          
             ws.send(
                 encodeMsg({
                     type: "MSG_WEAPONDOWN",
-                    data: {
-                        seta: targetInfo.seta
-                    }
+                    data: { slot: myPlayer(game.state).active, weaponId: weapon.id, args },
                 })
             );
         })
@@ -658,15 +778,16 @@ function appendSystemMessage(type, message) {
             let cellX= coordinates.x
             let cellY= coordinates.y
 
-            ws.send(
-                encodeMsg({
-                    type: "MSG_WEAPONUP",
-                    data: {
-                        x: cellX,
-                        y:cellY,
-                    },
-                })
-            );
+            const weapon = myWeapon();
+            const args = weapon && weapon.upArgs(cellX, cellY, targetInfo);
+            if (args && !weaponRejected) {
+                ws.send(
+                    encodeMsg({
+                        type: "MSG_WEAPONUP",
+                        data: { slot: myPlayer(game.state).active, weaponId: weapon.id, args },
+                    })
+                );
+            }
 
             //stop rendering the aiming
             amAiming=false;
@@ -690,12 +811,17 @@ function appendSystemMessage(type, message) {
 
             let targetInfo = getTargetInfo(mouseX,mouseY)
             targetLocation = { x: targetInfo.x, y: targetInfo.y };
+
+            const weapon = myWeapon();
+            const args = weapon && weapon.updateArgs(targetInfo);
+            if (!args || weaponRejected || Date.now() - lastAimAt < AIM_INTERVAL) {
+                return;
+            }
+            lastAimAt = Date.now();
             ws.send(
                 encodeMsg({
                     type: "MSG_WEAPONUPDATE",
-                    data: {
-                        seta: targetInfo.seta
-                    },
+                    data: { slot: myPlayer(game.state).active, weaponId: weapon.id, args },
                 })
             );
             
@@ -756,6 +882,31 @@ function appendSystemMessage(type, message) {
         chatInput.value = "";
     }
 
+    function configure(form) {
+        const data = { weapons: 0 };
+        for (const input of form.querySelectorAll("[data-setting]")) {
+            data[input.dataset.setting] = Number(input.value);
+        }
+        for (const input of form.querySelectorAll("input[data-weapon]:checked")) {
+            data.weapons |= 1 << input.dataset.weapon;
+        }
+        ws.send(
+            encodeMsg({
+                type: "MSG_CONFIGURE",
+                data,
+            })
+        );
+    }
+
+    function arm(select) {
+        ws.send(
+            encodeMsg({
+                type: "MSG_ARM",
+                data: { slot: Number(select.dataset.slot), weaponId: Number(select.value) },
+            })
+        );
+    }
+
     HomeScreen(root, {
         joinRoom,
         hostRoom,
@@ -768,19 +919,54 @@ function setupWSListeners(ws, handlers, root) {
         hostRoom,
         leaveRoom,
         chat,
+        configure,
+        arm,
         startGame,
         setupGameControls,
     } = handlers;
+    let resumeSent = false;
+    let resumePending = false;
     ws.addEventListener("open", () => {
         console.log("Connected");
+        let features = FEATURE_COMPRESSION | FEATURE_DELTA_STATE;
+        if (new URLSearchParams(location.search).has("debug")) {
+            features |= FEATURE_JSON_DEBUG;
+        }
+        ws.send(
+            encodeMsg({
+                type: "MSG_HELLO",
+                data: { version: PROTOCOL_VERSION, features },
+            })
+        );
     });
     ws.addEventListener("message", (event) => {
+        if (typeof event.data === "string") {
+            // FEATURE_JSON_DEBUG mirror of the binary message
+            console.debug(JSON.parse(event.data));
+            return;
+        }
         const msg = decodeMsg(event.data);
         switch (msg.type) {
             case "MSG_CNCT":
                 {
                     myData.id = msg.data.id;
                     myData.username = msg.data.username;
+                    myData.protocol = { version: msg.data.version, features: msg.data.features };
+
+                    // resume the previous session, the server keeps our seat for a while
+                    resumePending = false;
+                    const previous = sessionStorage.getItem("session");
+                    sessionStorage.setItem("session", msg.data.token);
+                    if (previous && previous !== msg.data.token && !resumeSent) {
+                        resumeSent = true;
+                        resumePending = true;
+                        ws.send(
+                            encodeMsg({
+                                type: "MSG_RESUME",
+                                data: { token: previous },
+                            })
+                        );
+                    }
                 }
                 break;
             case "MSG_HOSTED":
@@ -788,6 +974,8 @@ function setupWSListeners(ws, handlers, root) {
                     GameScreen(root, {
                         leaveRoom,
                         chat,
+                        configure,
+                        arm,
                         setupGameControls,
                     });
                 }
@@ -797,28 +985,71 @@ function setupWSListeners(ws, handlers, root) {
                     GameScreen(root, {
                         leaveRoom,
                         chat,
+                        configure,
+                        arm,
                         setupGameControls,
                     });
                 }
                 break;
             case "MSG_STATE":
+            case "MSG_STATEDELTA":
                 {
                     if (!game.state || !rendering) {
                         game.state = msg.data;
                         startGame();
                     } else {
+                        const previous = game.state;
                         game.state = msg.data;
+                        reconcile(previous, game.state);
                     }
                     isServerUpdated = true;
+                    refreshSettings();
+                    ws.send(
+                        encodeMsg({
+                            type: "MSG_STATEACK",
+                            data: { seq: msg.data.seq },
+                        })
+                    );
 
                     if (activeScreen !== 1) {
                         console.error("should be unreachable");
                     }
                 }
                 break;
+            case "MSG_CONFIGURED":
+                {
+                    game.settings = msg.data;
+                    refreshSettings();
+                }
+                break;
             case "MSG_MAP":
                 {
                     game.map = msg.data;
+                    // the entities on the new map follow it
+                    game.entities.clear();
+                    game.splashes = [];
+                }
+                break;
+            case "MSG_ENTITYSPAWNED":
+                {
+                    game.entities.set(msg.data.entityId, spawnEntity(msg.data));
+                }
+                break;
+            case "MSG_ENTITYTRIGGERED":
+                {
+                    const entity = game.entities.get(msg.data.entityId);
+                    if (!entity) break;
+                    game.splashes.push({
+                        x: msg.data.x,
+                        y: msg.data.y,
+                        team: entity.team,
+                        until: performance.now() + SPLASH_DURATION,
+                    });
+                }
+                break;
+            case "MSG_ENTITYDESPAWNED":
+                {
+                    game.entities.delete(msg.data.entityId);
                 }
                 break;
             case "MSG_LEFT":
@@ -828,6 +1059,7 @@ function setupWSListeners(ws, handlers, root) {
                         hostRoom,
                     });
                     gameState = undefined;
+                    game.entities.clear();
                 }
                 break;
             case "MSG_CHATTED":
@@ -837,6 +1069,12 @@ function setupWSListeners(ws, handlers, root) {
                 break;
             case "MSG_ERROR":
                 {
+                    if (resumePending) {
+                        // the old session is gone, carry on with the new one
+                        resumePending = false;
+                        console.log("Resume failed:", msg.data.message);
+                        break;
+                    }
                     if (!appendSystemMessage("SYS_MSG_ERROR", msg.data.message)) {
                         // TODO: find a better way to display error messages
                         alert(msg.data.message);
@@ -856,12 +1094,35 @@ function setupWSListeners(ws, handlers, root) {
                     game.map.tiles[y * game.map.width + x] = state;
                 }
                 break;
+            case "MSG_TILES":
+                {
+                    if (!game.map) break;
+                    for (const { x, y, tile } of msg.data.tiles) {
+                        game.map.tiles[y * game.map.width + x] = tile;
+                    }
+                    if (mapChecksum(game.map) !== msg.data.checksum) {
+                        ws.send(
+                            encodeMsg({
+                                type: "MSG_MAPMISMATCH",
+                            })
+                        );
+                    }
+                }
+                break;
             case "MSG_WEAPONPRESSED":
                 break;
             case "MSG_WEAPONUPDATED":
                 break;
             case "MSG_WEAPONRELEASED":
                 break;
+            case "MSG_WEAPONREJECTED":
+                {
+                    weaponRejected = true;
+                    amAiming = false;
+                    startBuildingAt = null;
+                    appendSystemMessage("SYS_MSG_ERROR", msg.data.reason);
+                }
+                break;
             default: {
                 console.error("Unknown message type:", msg.type);
             }
@@ -880,10 +1141,137 @@ function setupWSListeners(ws, handlers, root) {
         game.state = undefined;
         myData.id = undefined;
         myData.username = undefined;
-        // TODO: reconnect
+        // the server drops our held keys, and renumbers our inputs on resume
+        Object.assign(myInput, { seq: 0, up: false, down: false, left: false, right: false });
+        handlers.reconnect();
     });
 }
 
+/**
+ * Shows the room's match settings, only the host can edit them before the game starts
+ */
+function refreshSettings() {
+    const form = document.getElementById("settings");
+    if (!form || !game.settings) return;
+
+    const inLobby = game.state && game.state.state.phase === WaitingForPlayers;
+    const editable = inLobby && game.state.host === myData.id;
+    for (const input of form.querySelectorAll("[data-setting]")) {
+        if (document.activeElement !== input) {
+            input.value = game.settings[input.dataset.setting];
+        }
+        input.disabled = !editable;
+    }
+    for (const input of form.querySelectorAll("input[data-weapon]")) {
+        input.checked = (game.settings.weapons & (1 << input.dataset.weapon)) !== 0;
+        input.disabled = !editable;
+    }
+    form.querySelector("button").disabled = !editable;
+
+    // the weapon pickers only offer what the room allows
+    const me = myPlayer(game.state);
+    const allowed = weaponIds().filter((id) => game.settings.weapons & (1 << id));
+    for (const select of document.querySelectorAll("select.weapon")) {
+        if (select.options.length !== allowed.length || [...select.options].some((o, i) => Number(o.value) !== allowed[i])) {
+            select.replaceChildren(...allowed.map((id) => new Option(weaponName(id), id)));
+        }
+        if (me && document.activeElement !== select) {
+            select.value = me.loadout[select.dataset.slot].weaponId;
+        }
+        select.disabled = !inLobby;
+    }
+}
+
+// loadMapPool adds the maps of the pool to the map picker
+async function loadMapPool(select) {
+    try {
+        const res = await fetch("/maps");
+        for (const map of await res.json()) {
+            const author = map.author ? ` by ${map.author}` : "";
+            select.appendChild(new Option(`${map.name} (${map.width}x${map.height})${author}`, map.id));
+        }
+        refreshSettings();
+    } catch (err) {
+        console.error("could not load the map pool", err);
+    }
+}
+
+function weaponIds() {
+    return Object.keys(WEAPONS).filter((key) => !isNaN(key)).map(Number);
+}
+
+function weaponName(id) {
+    return WEAPONS[id].replace("WEAPON_", "");
+}
+
+function myPlayer(state) {
+    if (!state) return undefined;
+    return state.players.find((player) => player.user.id === myData.id);
+}
+
+/**
+ * renders the player's weapon name, its cooldown and charge, and the player's
+ * ink
+ */
+function renderWeaponStatus(ctx, player, x, y, width) {
+    const { cooldown, charge } = player.weaponStatus;
+
+    ctx.fillStyle = "#f0f0f0";
+    ctx.font = "20px Arial";
+    const holstered = player.loadout[(player.active + 1) % LOADOUT_SIZE].weaponId;
+    ctx.fillText(`${weaponName(player.weaponId)} (E: ${weaponName(holstered)})`, x + width / 2, y, width);
+    ctx.fillText(cooldown > 0 ? `${cooldown.toFixed(1)}s` : "Ready", x + width / 2, y + 30, width);
+
+    // charge
+    ctx.fillStyle = "#555555";
+    ctx.fillRect(x, y + 55, width, 10);
+    ctx.fillStyle = "#fcbe03";
+    ctx.fillRect(x, y + 55, width * charge, 10);
+
+    // ink
+    ctx.fillStyle = "#555555";
+    ctx.fillRect(x, y + 75, width, 10);
+    ctx.fillStyle = "#03a9fc";
+    ctx.fillRect(x, y + 75, width * player.ink, 10);
+    ctx.font = "30px Arial";
+}
+
+function myWeapon() {
+    const me = myPlayer(game.state);
+    return me && me.weapon;
+}
+
+function inputVelocity(input) {
+    return [
+        (input.right ? 1 : 0) - (input.left ? 1 : 0),
+        (input.down ? 1 : 0) - (input.up ? 1 : 0),
+    ];
+}
+
+/**
+ * Keeps our own player where we predicted it, until the server has applied
+ * our latest input, then eases it toward the server position.
+ */
+function reconcile(previous, next) {
+    const predicted = myPlayer(previous);
+    const me = myPlayer(next);
+    if (!predicted || !me || !previous.started || !next.started) return;
+
+    if (me.inputSeq < myInput.seq) {
+        me.x = predicted.x;
+        me.y = predicted.y;
+        [me.vx, me.vy] = inputVelocity(myInput);
+        return;
+    }
+
+    const dx = me.x - predicted.x;
+    const dy = me.y - predicted.y;
+    if (Math.hypot(dx, dy) < RECONCILE_SNAP) {
+        me.x = predicted.x + dx * RECONCILE_BLEND;
+        me.y = predicted.y + dy * RECONCILE_BLEND;
+    }
+}
+
 function getFromMap(map, x, y) {
     if (x < 0 || y < 0 || x >= map.width || y >= map.height) {
         return WallTile;
@@ -1031,6 +1419,88 @@ function canvasPixelToMapCell(x, y) {
  * @param {number} x 
  * @param {number} y 
  */ 
+/**
+ * decodes the kind specific arguments of a spawned entity
+ */
+function spawnEntity(entity) {
+    switch (entity.kind) {
+        case GrenadeEntity: {
+            const view = new DataView(entity.args.buffer, entity.args.byteOffset, entity.args.byteLength);
+            const state = { i: 0 };
+            entity.vx = getFloat64(view, state);
+            entity.vy = getFloat64(view, state);
+            entity.flight = getFloat64(view, state);
+        } break;
+    }
+    return entity;
+}
+
+/**
+ * moves a grenade in flight like the server does, bouncing off walls
+ */
+function updateEntity(entity, dt, map) {
+    if (entity.kind !== GrenadeEntity || entity.flight <= 0) {
+        return;
+    }
+    const step = Math.min(dt, entity.flight);
+    entity.flight -= step;
+
+    const blocked = (x, y) => getFromMap(map, Math.floor(x + 0.5), Math.floor(y + 0.5)) === WallTile;
+    let x = entity.x + entity.vx * step;
+    let y = entity.y + entity.vy * step;
+    if (blocked(x, entity.y)) {
+        entity.vx = -entity.vx;
+        x = entity.x + entity.vx * step;
+    }
+    if (blocked(x, y)) {
+        entity.vy = -entity.vy;
+        y = entity.y + entity.vy * step;
+    }
+    if (blocked(x, y)) {
+        entity.vx = 0;
+        entity.vy = 0;
+        return;
+    }
+    entity.x = x;
+    entity.y = y;
+}
+
+function renderSplash(ctx, splash, color, now) {
+    const [x, y] = mapCellToCanvasPixel(splash.x + 0.5, splash.y + 0.5);
+    const cellWidth = (1600 * 0.9) / game.map.width;
+    const progress = 1 - (splash.until - now) / SPLASH_DURATION;
+    ctx.strokeStyle = color;
+    ctx.lineWidth = 6;
+    ctx.beginPath();
+    ctx.arc(x, y, (0.5 + progress) * cellWidth, 0, 2 * Math.PI);
+    ctx.stroke();
+}
+
+function renderEntity(ctx, entity, color) {
+    switch (entity.kind) {
+        case MineEntity: {
+            const [x, y] = mapCellToCanvasPixel(entity.x + 0.5, entity.y + 0.5);
+            ctx.fillStyle = color;
+            ctx.strokeStyle = "#353535";
+            ctx.lineWidth = 3;
+            ctx.beginPath();
+            ctx.arc(x, y, 10, 0, 2 * Math.PI);
+            ctx.fill();
+            ctx.stroke();
+        } break;
+        case GrenadeEntity: {
+            const [x, y] = mapCellToCanvasPixel(entity.x + 0.5, entity.y + 0.5);
+            ctx.fillStyle = color;
+            ctx.strokeStyle = "white";
+            ctx.lineWidth = 2;
+            ctx.beginPath();
+            ctx.arc(x, y, 8, 0, 2 * Math.PI);
+            ctx.fill();
+            ctx.stroke();
+        } break;
+    }
+}
+
 function mapCellToCanvasPixel(x, y) {
     let xOffset = 1600 * 0.1
     let yOffset = 900 * 0.1
@@ -1,12 +1,17 @@
 // Weapons
 const WEAPONS = {};
 WEAPONS[WEAPONS["WEAPON_Grenade"] = 0] = "WEAPON_Grenade";
+WEAPONS[WEAPONS["WEAPON_Roller"] = 1] = "WEAPON_Roller";
+WEAPONS[WEAPONS["WEAPON_Charger"] = 2] = "WEAPON_Charger";
+WEAPONS[WEAPONS["WEAPON_Mine"] = 3] = "WEAPON_Mine";
 
 /**
  * @class Weapon
  */
 class Weapon {
+    id;
     player_index;
+    aims = false; // whether the mouse aims the weapon while it is held
     constructor(player_index) {
         if(this.constructor == Weapon) {
             throw new Error("Abstract classes can't be instantiated.");
@@ -19,14 +24,33 @@ class Weapon {
     onWeaponReleasedMSG(msg,player_index) {}
     render(ctx){}
 
+    // arguments of the weapon messages we send, null to not send the message
+    downArgs(target) { return null; }
+    updateArgs(target) { return null; }
+    upArgs(x, y, target) { return null; }
+
+    speedFactor() { return 1; }
+
     static getWeaponObject(id,index) {
-    
+        let weapon = null;
         switch (WEAPONS[id]) {
             case "WEAPON_Grenade":
-                return new Grenade(index);
+                weapon = new Grenade(index);
+                break;
+            case "WEAPON_Roller":
+                weapon = new Roller(index);
+                break;
+            case "WEAPON_Charger":
+                weapon = new Charger(index);
+                break;
+            case "WEAPON_Mine":
+                weapon = new Mine(index);
+                break;
             default:
                 return null;
         }
+        weapon.id = id;
+        return weapon;
     }
 
     static decodeWeaponPressedMSG(msg) {
@@ -55,10 +79,9 @@ class Weapon {
 }
 
 class Grenade extends Weapon {
+    aims = true;
     isAiming = false;
-    isShooting=false;
     seta=null;
-    target=null;
     constructor(player_index) {
         super(player_index);
         
@@ -92,6 +115,12 @@ class Grenade extends Weapon {
         
         // check the length of the message
         let msg_len = getUint8(view, state);
+        if(msg_len == 0){
+            // released without throwing
+            this.isAiming=false;
+            this.seta=null;
+            return data;
+        }
         if(msg_len != 16){
             throw new Error("Invalid message length "+msg_len);
         }
@@ -99,47 +128,16 @@ class Grenade extends Weapon {
         data.x = getFloat64(view, state);
         data.y = getFloat64(view, state);
 
-        //get the tiles state by getting the teamId and add 1 to it to get the tile code.
-        let tiles_state=game.state.players[this.player_index].team + 1
-
-        this.target = data;
-        this.isShooting=true;
+        // the thrown grenade arrives as an entity in MSG_ENTITYSPAWNED
         this.isAiming=false;
         this.seta=null;
-
-        setTimeout(() => {
-            this.onWeaponReleased(data.x,data.y, tiles_state);
-        }, 100);
-
-        setTimeout(() => {
-            this.isShooting=false;
-            this.target=null;
-        }, 600);
         
         return data;
     }
 
-    onWeaponReleased(x,y,state){
-        x=Math.floor(x);
-        y=Math.floor(y);
-
-        for(let i=x-1;i<x+2;i++){
-            for(let j=y-1;j<y+2;j++){
-                let tile_index=(j * game.map.width + i)
-                
-                //if the tile is a wall continue
-                if(game.map.tiles[tile_index]==WallTile)continue
-
-                if(i>=0 && j>=0 && i<game.map.width && j<game.map.height){
-                    game.map.tiles[tile_index] = state;
-                }
-            }
-        }
-    }
-
     render(ctx){
         
-        if(!this.isAiming && !this.isShooting){
+        if(!this.isAiming){
             return;
         }
 
@@ -160,15 +158,181 @@ class Grenade extends Weapon {
             ctx.lineTo(...mapCellToCanvasPixel(x, y));
             ctx.stroke();  
 
-        }else if(this.isShooting){
-            ctx.lineWidth = 5; 
+        }
+    }
+
+    downArgs(target) {
+        const w = new MsgWriter();
+        w.Float64(target.seta);
+        return w.bytes();
+    }
+
+    updateArgs(target) {
+        return this.downArgs(target);
+    }
+
+    upArgs(x, y) {
+        const w = new MsgWriter();
+        w.Float64(x);
+        w.Float64(y);
+        return w.bytes();
+    }
+}
+
+// must match the roller in wepons/roller.go
+const ROLLER_SPEED_FACTOR = 0.6;
+
+class Roller extends Weapon {
+    isRolling = false;
+    constructor(player_index) {
+        super(player_index);
+    }
+
+    // the painted tiles arrive from the server in MSG_TILES
+    onWeaponPressedMSG(msg) {
+        this.isRolling = true;
+        return {};
+    }
+
+    onWeaponReleasedMSG(msg) {
+        this.isRolling = false;
+        return {};
+    }
+
+    render(ctx) {
+        if (!this.isRolling) {
+            return;
+        }
+
+        const player = game.state.players[this.player_index];
+        const [x, y] = mapCellToCanvasPixel(player.x + 0.5, player.y + 0.5);
+        ctx.lineWidth = 4;
+        ctx.strokeStyle = "white";
+        ctx.beginPath();
+        ctx.arc(x, y, 16, 0, 2 * Math.PI);
+        ctx.stroke();
+    }
+
+    downArgs(target) {
+        return new Uint8Array(0);
+    }
+
+    upArgs(x, y) {
+        return new Uint8Array(0);
+    }
+
+    speedFactor() {
+        return this.isRolling ? ROLLER_SPEED_FACTOR : 1;
+    }
+}
+
+class Charger extends Weapon {
+    aims = true;
+    isCharging = false;
+    isShooting = false;
+    seta = null;
+    line = null;
+    constructor(player_index) {
+        super(player_index);
+    }
+
+    onWeaponPressedMSG(msg) {
+        this.isCharging = true;
+        return this.onWeaponUpdatedMSG(msg);
+    }
+
+    onWeaponUpdatedMSG(msg) {
+        const view = new DataView(msg, 4);
+        const state = { i: 0 };
+
+        let msg_len = getUint8(view, state);
+        if(msg_len != 8){
+            throw new Error("Invalid message length "+msg_len);
+        }
+
+        this.seta = getFloat64(view, state);
+        return {};
+    }
+
+    onWeaponReleasedMSG(msg) {
+        const view = new DataView(msg, 4);
+        const state = { i: 0 };
+
+        let msg_len = getUint8(view, state);
+        if(msg_len == 0){
+            // released without firing
+            this.isCharging = false;
+            this.seta = null;
+            return {};
+        }
+        if(msg_len != 32){
+            throw new Error("Invalid message length "+msg_len);
+        }
+
+        // the painted tiles arrive from the server in MSG_TILES
+        const data = {
+            x0: getFloat64(view, state),
+            y0: getFloat64(view, state),
+            x1: getFloat64(view, state),
+            y1: getFloat64(view, state),
+        };
+        this.line = data;
+        this.isShooting = true;
+        this.isCharging = false;
+        this.seta = null;
+
+        setTimeout(() => {
+            this.isShooting = false;
+            this.line = null;
+        }, 400);
+
+        return data;
+    }
+
+    render(ctx) {
+        if (this.isCharging && this.seta != null) {
+            const player = game.state.players[this.player_index];
+            const x = player.x + 0.5 - 2 * Math.cos(this.seta);
+            const y = player.y + 0.5 - 2 * Math.sin(this.seta);
+
+            ctx.lineWidth = 4;
+            ctx.strokeStyle = "yellow";
             ctx.beginPath();
-            ctx.strokeStyle = "red";
             ctx.moveTo(...mapCellToCanvasPixel(player.x + 0.5, player.y + 0.5));
-            ctx.lineTo(...mapCellToCanvasPixel(this.target.x, this.target.y));
-            ctx.stroke();  
+            ctx.lineTo(...mapCellToCanvasPixel(x, y));
+            ctx.stroke();
+        } else if (this.isShooting) {
+            ctx.lineWidth = 6;
+            ctx.strokeStyle = "yellow";
+            ctx.beginPath();
+            ctx.moveTo(...mapCellToCanvasPixel(this.line.x0, this.line.y0));
+            ctx.lineTo(...mapCellToCanvasPixel(this.line.x1, this.line.y1));
+            ctx.stroke();
         }
     }
 
-    /** @todo: use this class to handle the own player weapon actions */
-}
\ No newline at end of file
+    downArgs(target) {
+        const w = new MsgWriter();
+        w.Float64(target.seta);
+        return w.bytes();
+    }
+
+    updateArgs(target) {
+        return this.downArgs(target);
+    }
+
+    upArgs(x, y, target) {
+        return this.downArgs(target);
+    }
+}
+
+// the mine is dropped on release, the server announces it with MSG_ENTITYSPAWNED
+class Mine extends Weapon {
+    constructor(player_index) {
+        super(player_index);
+    }
+
+    upArgs(x, y, target) {
+        return new Uint8Array(0);
+    }
+}
@@ -6,9 +6,22 @@ type GamePhase uint8
 type TeamID uint8
 
 type GameMap struct {
-	Width  int
-	Height int
-	Tiles  []Tile
+	Width   int
+	Height  int
+	Tiles   []Tile
+	Journal *TileJournal `json:"-"` // records changed tiles when set
+}
+
+// TileJournal records the indexes of the tiles changed on a GameMap since it
+// was last flushed
+type TileJournal struct {
+	Changed []int
+}
+
+type TileChange struct {
+	X    int
+	Y    int
+	Tile Tile
 }
 
 type GameState struct {
@@ -35,7 +48,28 @@ type StateMessagePlayer struct {
 	VX       int32
 	VY       int32
 	User     StateMessageUser
+	Active   Slot // slot of the weapon in hand
+	Loadout  [LoadoutSize]LoadoutSlot
+	Ink      uint8  // percent of a full tank
+	InputSeq uint32 // last input of the player the server applied
+}
+
+// LoadoutSize is how many weapon slots a player has
+const LoadoutSize = 2
+
+type Slot uint8
+
+// LoadoutSlot is a weapon in a player's loadout
+type LoadoutSlot struct {
 	WeaponId WeaponId
+	Weapon   WeaponStatus
+}
+
+// WeaponStatus is what the players see of a weapon's state
+type WeaponStatus struct {
+	Cooldown uint16 // milliseconds left
+	Charge   uint8  // out of 255, while charging
+	Ammo     uint8  // out of 255, ink or ammo left
 }
 
 type StateMessageUser struct {
@@ -44,3 +78,5 @@ type StateMessageUser struct {
 }
 
 type WeaponId uint8
+
+type EntityKind uint8
@@ -0,0 +1,187 @@
+package wepons
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"online-game/entities"
+	"online-game/msgs"
+	"online-game/types"
+	"time"
+)
+
+const chargerId = entities.ChargerId
+const chargerName = "Charger"
+const chargerMinLength = 4  // tiles, when released right away
+const chargerMaxLength = 24 // tiles, when fully charged
+const chargerFullCharge = 1500 * time.Millisecond
+const chargerMinCharge = 100 * time.Millisecond
+const chargerCooldown = 2
+const chargerInk = 15
+
+// Charger charges while held down, and on release paints a straight line
+// along its aim that stops at the first wall. The longer it charged, the
+// longer the line.
+type Charger struct {
+	cycle
+}
+
+func init() {
+	Register(chargerId, func() entities.Weapon {
+		return &Charger{cycle{
+			cooldown:   chargerCooldown * time.Second,
+			minCharge:  chargerMinCharge,
+			fullCharge: chargerFullCharge,
+			aimEvery:   aimEvery,
+		}}
+	})
+}
+
+// ChargerPressed starts charging, aimed at Seta
+type ChargerPressed struct {
+	charger *Charger
+	Seta    float64
+}
+
+// ChargerAimed turns the charging charger to Seta
+type ChargerAimed struct {
+	charger *Charger
+	Seta    float64
+}
+
+// ChargerReleased fires the charger at Seta
+type ChargerReleased struct {
+	charger *Charger
+	Seta    float64
+}
+
+func (e ChargerPressed) Apply(ctx *entities.WeaponContext) error {
+	return e.charger.OnWeaponDown(ctx, e)
+}
+
+func (e ChargerAimed) Apply(ctx *entities.WeaponContext) error {
+	return e.charger.OnWeaponUpdate(ctx, e)
+}
+
+func (e ChargerReleased) Apply(ctx *entities.WeaponContext) error {
+	return e.charger.OnWeaponUp(ctx, e)
+}
+
+func (c *Charger) Id() types.WeaponId {
+	return chargerId
+}
+
+func (c *Charger) Name() string {
+	return chargerName
+}
+
+func (c *Charger) GetCooldown() int {
+	return chargerCooldown
+}
+
+func (c *Charger) Stringify() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     c.Name(),
+		"cooldown": c.GetCooldown(),
+	}
+}
+
+func (c *Charger) Reset() {
+	c.cancel()
+}
+
+func (c *Charger) OnWeaponDown(ctx *entities.WeaponContext, e ChargerPressed) error {
+	if ctx.Player.Ink < chargerInk {
+		return entities.ErrOutOfInk
+	}
+	if err := c.press(); err != nil {
+		return err
+	}
+
+	ctx.Notify(msgs.WeaponPressedMessage{
+		WeaponId: chargerId,
+		PlayerId: ctx.Player.User.ID,
+		Args:     setaArgs(e.Seta),
+	})
+
+	return nil
+}
+
+func (c *Charger) OnWeaponUpdate(ctx *entities.WeaponContext, e ChargerAimed) error {
+	if err := c.aim(); err != nil {
+		return err
+	}
+
+	ctx.Notify(msgs.WeaponUpdatedMessage{
+		WeaponId: chargerId,
+		PlayerId: ctx.Player.User.ID,
+		Args:     setaArgs(e.Seta),
+	})
+
+	return nil
+}
+
+func (c *Charger) OnWeaponUp(ctx *entities.WeaponContext, e ChargerReleased) error {
+	if c.state() == charging && ctx.Player.Ink < chargerInk {
+		c.cancel()
+		notifyCanceled(ctx, chargerId)
+		return entities.ErrOutOfInk
+	}
+	charged, err := c.release()
+	if err != nil {
+		if err == ErrUndercharged {
+			notifyCanceled(ctx, chargerId)
+		}
+		return err
+	}
+	ctx.Player.UseInk(chargerInk)
+
+	charge := math.Min(charged.Seconds()/chargerFullCharge.Seconds(), 1)
+	length := chargerMinLength + (chargerMaxLength-chargerMinLength)*charge
+
+	// seta points from the target back to the player, as for the grenade
+	player := ctx.Player
+	x0, y0 := player.X+0.5, player.Y+0.5
+	cells, x1, y1 := entities.Raycast(&ctx.Game.State.GameMap, x0, y0, -math.Cos(e.Seta), -math.Sin(e.Seta), length)
+	for _, cell := range cells {
+		ctx.Game.Paint(cell.X, cell.Y, player.Team)
+	}
+
+	// the beam's endpoints, the painted tiles arrive in the tick's TilesMessage
+	lineBuf := &bytes.Buffer{}
+	binary.Write(lineBuf, binary.LittleEndian, x0)
+	binary.Write(lineBuf, binary.LittleEndian, y0)
+	binary.Write(lineBuf, binary.LittleEndian, x1)
+	binary.Write(lineBuf, binary.LittleEndian, y1)
+	ctx.Broadcast(msgs.WeaponReleasedMessage{
+		WeaponId: chargerId,
+		PlayerId: player.User.ID,
+		Args:     lineBuf.Bytes(),
+	})
+
+	return nil
+}
+
+func (c *Charger) ParseWeaponDownMessage(args []byte) (entities.WeaponEvent, bool) {
+	seta, ok := parseSeta(args)
+	if !ok {
+		return nil, false
+	}
+	return ChargerPressed{charger: c, Seta: seta}, true
+}
+
+func (c *Charger) ParseWeaponUpdateMessage(args []byte) (entities.WeaponEvent, bool) {
+	seta, ok := parseSeta(args)
+	if !ok {
+		return nil, false
+	}
+	return ChargerAimed{charger: c, Seta: seta}, true
+}
+
+func (c *Charger) ParseWeaponUpMessage(args []byte) (entities.WeaponEvent, bool) {
+	seta, ok := parseSeta(args)
+	if !ok {
+		return nil, false
+	}
+	return ChargerReleased{charger: c, Seta: seta}, true
+}
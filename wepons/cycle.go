@@ -0,0 +1,145 @@
+package wepons
+
+import (
+	"errors"
+	"math"
+	"online-game/entities"
+	"online-game/msgs"
+	"online-game/types"
+	"time"
+)
+
+// aimEvery is the least time between two aim updates a weapon takes
+const aimEvery = 50 * time.Millisecond
+
+// Errors a weapon message is rejected with when it does not fit the weapon's
+// cycle
+var (
+	ErrCharging     = errors.New("already charging")
+	ErrCoolingDown  = errors.New("still cooling down")
+	ErrNotCharging  = errors.New("not charging")
+	ErrUndercharged = errors.New("released before it charged")
+	// ErrThrottled is returned for aim updates sent faster than the weapon
+	// takes them, they are dropped without telling the player
+	ErrThrottled = errors.New("aiming too fast")
+)
+
+type cycleState uint8
+
+const (
+	idle        cycleState = iota
+	charging    cycleState = iota
+	coolingDown cycleState = iota
+)
+
+// cycle is a weapon's fire cycle: Idle → Charging → Cooldown → Idle, or
+// Idle → Cooldown for weapons that fire right away. The weapons check every
+// message against it before acting on it.
+type cycle struct {
+	cooldown   time.Duration
+	minCharge  time.Duration // least charge a release is taken with
+	fullCharge time.Duration // charge past which charging has no effect
+	aimEvery   time.Duration // least time between two aim updates
+
+	chargingSince time.Time // zero unless charging
+	coolingSince  time.Time
+	lastAim       time.Time
+}
+
+func (c *cycle) state() cycleState {
+	if !c.chargingSince.IsZero() {
+		return charging
+	}
+	if time.Since(c.coolingSince) < c.cooldown {
+		return coolingDown
+	}
+	return idle
+}
+
+func (c *cycle) GetCooldownLeft() float64 {
+	return math.Max(c.cooldown.Seconds()-time.Since(c.coolingSince).Seconds(), 0)
+}
+
+// GetCharge is 0 unless charging, and 1 while charging a weapon without a
+// full charge
+func (c *cycle) GetCharge() float64 {
+	if c.state() != charging {
+		return 0
+	}
+	if c.fullCharge <= 0 {
+		return 1
+	}
+	return math.Min(time.Since(c.chargingSince).Seconds()/c.fullCharge.Seconds(), 1)
+}
+
+// GetAmmo is always full, weapons with ammo override it
+func (c *cycle) GetAmmo() float64 {
+	return 1
+}
+
+// press starts charging
+func (c *cycle) press() error {
+	switch c.state() {
+	case charging:
+		return ErrCharging
+	case coolingDown:
+		return ErrCoolingDown
+	}
+	c.chargingSince = time.Now()
+	c.lastAim = c.chargingSince
+	return nil
+}
+
+// aim checks an aim update is taken while charging, and not too soon after
+// the last one
+func (c *cycle) aim() error {
+	if c.state() != charging {
+		return ErrNotCharging
+	}
+	if time.Since(c.lastAim) < c.aimEvery {
+		return ErrThrottled
+	}
+	c.lastAim = time.Now()
+	return nil
+}
+
+// release ends the charge and starts the cooldown, returning how long the
+// weapon charged. A release before minCharge cancels the charge instead.
+func (c *cycle) release() (time.Duration, error) {
+	if c.state() != charging {
+		return 0, ErrNotCharging
+	}
+	charged := time.Since(c.chargingSince)
+	c.chargingSince = time.Time{}
+	if charged < c.minCharge {
+		return 0, ErrUndercharged
+	}
+	c.coolingSince = time.Now()
+	return charged, nil
+}
+
+// fire starts the cooldown of a weapon that does not charge
+func (c *cycle) fire() error {
+	switch c.state() {
+	case charging:
+		return ErrCharging
+	case coolingDown:
+		return ErrCoolingDown
+	}
+	c.coolingSince = time.Now()
+	return nil
+}
+
+// cancel drops the charge, without a cooldown
+func (c *cycle) cancel() {
+	c.chargingSince = time.Time{}
+}
+
+// notifyCanceled tells the other players a weapon stopped charging without
+// firing, with a WeaponReleasedMessage without arguments
+func notifyCanceled(ctx *entities.WeaponContext, weaponId types.WeaponId) {
+	ctx.Notify(msgs.WeaponReleasedMessage{
+		WeaponId: weaponId,
+		PlayerId: ctx.Player.User.ID,
+	})
+}
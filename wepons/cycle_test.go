@@ -0,0 +1,196 @@
+package wepons
+
+import (
+	"testing"
+	"time"
+)
+
+// ago is a time d before now, to put a cycle part way through a state
+// without sleeping
+func ago(d time.Duration) time.Time {
+	return time.Now().Add(-d)
+}
+
+func testCycle() cycle {
+	return cycle{
+		cooldown:   time.Second,
+		minCharge:  100 * time.Millisecond,
+		fullCharge: time.Second,
+		aimEvery:   aimEvery,
+	}
+}
+
+func TestCycleTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *cycle)
+		op    func(c *cycle) error
+		err   error
+		after cycleState
+	}{
+		{
+			name:  "press when idle",
+			op:    (*cycle).press,
+			after: charging,
+		},
+		{
+			name:  "press while charging",
+			setup: func(c *cycle) { c.chargingSince = ago(time.Millisecond) },
+			op:    (*cycle).press,
+			err:   ErrCharging,
+			after: charging,
+		},
+		{
+			name:  "press while cooling down",
+			setup: func(c *cycle) { c.coolingSince = ago(time.Millisecond) },
+			op:    (*cycle).press,
+			err:   ErrCoolingDown,
+			after: coolingDown,
+		},
+		{
+			name:  "press after the cooldown",
+			setup: func(c *cycle) { c.coolingSince = ago(2 * time.Second) },
+			op:    (*cycle).press,
+			after: charging,
+		},
+		{
+			name:  "aim when idle",
+			op:    (*cycle).aim,
+			err:   ErrNotCharging,
+			after: idle,
+		},
+		{
+			name: "aim while charging",
+			setup: func(c *cycle) {
+				c.chargingSince = ago(time.Second)
+				c.lastAim = ago(2 * aimEvery)
+			},
+			op:    (*cycle).aim,
+			after: charging,
+		},
+		{
+			name: "aim too soon",
+			setup: func(c *cycle) {
+				c.chargingSince = ago(time.Second)
+				c.lastAim = ago(aimEvery / 2)
+			},
+			op:    (*cycle).aim,
+			err:   ErrThrottled,
+			after: charging,
+		},
+		{
+			name:  "release when idle",
+			op:    func(c *cycle) error { _, err := c.release(); return err },
+			err:   ErrNotCharging,
+			after: idle,
+		},
+		{
+			name:  "release charged",
+			setup: func(c *cycle) { c.chargingSince = ago(200 * time.Millisecond) },
+			op:    func(c *cycle) error { _, err := c.release(); return err },
+			after: coolingDown,
+		},
+		{
+			name:  "release before the least charge",
+			setup: func(c *cycle) { c.chargingSince = ago(10 * time.Millisecond) },
+			op:    func(c *cycle) error { _, err := c.release(); return err },
+			err:   ErrUndercharged,
+			after: idle,
+		},
+		{
+			name:  "fire when idle",
+			op:    (*cycle).fire,
+			after: coolingDown,
+		},
+		{
+			name:  "fire while charging",
+			setup: func(c *cycle) { c.chargingSince = ago(time.Millisecond) },
+			op:    (*cycle).fire,
+			err:   ErrCharging,
+			after: charging,
+		},
+		{
+			name:  "fire while cooling down",
+			setup: func(c *cycle) { c.coolingSince = ago(time.Millisecond) },
+			op:    (*cycle).fire,
+			err:   ErrCoolingDown,
+			after: coolingDown,
+		},
+		{
+			name:  "cancel while charging",
+			setup: func(c *cycle) { c.chargingSince = ago(200 * time.Millisecond) },
+			op:    func(c *cycle) error { c.cancel(); return nil },
+			after: idle,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testCycle()
+			if test.setup != nil {
+				test.setup(&c)
+			}
+			if err := test.op(&c); err != test.err {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if state := c.state(); state != test.after {
+				t.Fatalf("state = %d, want %d", state, test.after)
+			}
+		})
+	}
+}
+
+func TestCycleReleaseCharge(t *testing.T) {
+	c := testCycle()
+	c.chargingSince = ago(300 * time.Millisecond)
+	charged, err := c.release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charged < 300*time.Millisecond || charged > time.Second {
+		t.Fatalf("charged = %v, want about 300ms", charged)
+	}
+	if left := c.GetCooldownLeft(); left <= 0.9 || left > 1 {
+		t.Fatalf("cooldown left = %v, want about 1", left)
+	}
+}
+
+func TestCycleCooldownLeft(t *testing.T) {
+	c := testCycle()
+	if left := c.GetCooldownLeft(); left != 0 {
+		t.Fatalf("idle cooldown left = %v, want 0", left)
+	}
+
+	last := c.cooldown.Seconds()
+	for _, since := range []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond} {
+		c.coolingSince = ago(since)
+		left := c.GetCooldownLeft()
+		if left >= last || left <= 0 {
+			t.Fatalf("cooldown left %v after %v, not counting down from %v", left, since, last)
+		}
+		last = left
+	}
+
+	c.coolingSince = ago(2 * c.cooldown)
+	if left := c.GetCooldownLeft(); left != 0 {
+		t.Fatalf("cooldown left = %v after the cooldown, want 0", left)
+	}
+	if state := c.state(); state != idle {
+		t.Fatalf("state = %d after the cooldown, want idle", state)
+	}
+}
+
+func TestCycleGetCharge(t *testing.T) {
+	c := testCycle()
+	if charge := c.GetCharge(); charge != 0 {
+		t.Fatalf("idle charge = %v, want 0", charge)
+	}
+	c.chargingSince = ago(c.fullCharge / 2)
+	if charge := c.GetCharge(); charge < 0.5 || charge > 0.6 {
+		t.Fatalf("half charge = %v", charge)
+	}
+	c.chargingSince = ago(2 * c.fullCharge)
+	if charge := c.GetCharge(); charge != 1 {
+		t.Fatalf("full charge = %v, want 1", charge)
+	}
+}
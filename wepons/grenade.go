@@ -18,10 +18,24 @@ const rang_constB = 2
 const max_range = 5
 const hitBox = 3
 const cooldown = 8
+const inkCost = 20
+const minCharge = 100 * time.Millisecond
+const fullCharge = (max_range - rang_constB) / rang_constA * time.Second // charge reaching max_range
+const grenadeSpeed = 8                                                   // tiles per second in flight
 
 type Grenade struct {
-	startBuildingAt time.Time
-	startCoolDownAt time.Time
+	cycle
+}
+
+func init() {
+	Register(id, func() entities.Weapon {
+		return &Grenade{cycle{
+			cooldown:   cooldown * time.Second,
+			minCharge:  minCharge,
+			fullCharge: fullCharge,
+			aimEvery:   aimEvery,
+		}}
+	})
 }
 
 func (g *Grenade) Id() types.WeaponId {
@@ -32,10 +46,6 @@ func (g *Grenade) GetCooldown() int {
 	return cooldown
 }
 
-func (g *Grenade) GetCooldownLeft() float64 {
-	return time.Since(g.startCoolDownAt).Seconds()
-}
-
 func (g *Grenade) Name() string {
 	return name
 }
@@ -47,66 +57,129 @@ func (g *Grenade) Stringify() map[string]interface{} {
 	}
 }
 
-func (g *Grenade) OnWeaponDown(game *entities.Game, player *entities.Player, data map[string]interface{}) (map[string]interface{}, error) {
-	//handle edge cases: (allredy building range, still cooling down)
-	if !g.startBuildingAt.Equal(time.Time{}) {
-		return nil, errors.New("allredy building range")
-	}
-	if time.Since(g.startCoolDownAt).Seconds() < cooldown {
-		return nil, errors.New("still cooling down")
-	}
+// GrenadePressed starts charging the grenade, aimed at Seta
+type GrenadePressed struct {
+	grenade *Grenade
+	Seta    float64
+}
 
-	//save time
-	g.startBuildingAt = time.Now()
+// GrenadeAimed turns the charging grenade to Seta
+type GrenadeAimed struct {
+	grenade *Grenade
+	Seta    float64
+}
 
-	//notify the other players
+// GrenadeReleased throws the grenade at X, Y
+type GrenadeReleased struct {
+	grenade *Grenade
+	X       float64
+	Y       float64
+}
+
+func (e GrenadePressed) Apply(ctx *entities.WeaponContext) error {
+	return e.grenade.OnWeaponDown(ctx, e)
+}
+
+func (e GrenadeAimed) Apply(ctx *entities.WeaponContext) error {
+	return e.grenade.OnWeaponUpdate(ctx, e)
+}
+
+func (e GrenadeReleased) Apply(ctx *entities.WeaponContext) error {
+	return e.grenade.OnWeaponUp(ctx, e)
+}
+
+func setaArgs(seta float64) []byte {
 	setaBuf := &bytes.Buffer{}
-	binary.Write(setaBuf, binary.LittleEndian, float64(data["seta"].(float64)))
-	msg := msgs.WeaponPressedMessage{
-		WeaponId: id,
-		PlayerId: player.User.ID,
-		Args:     setaBuf.Bytes(),
-	}
-	buf, _ := msg.Buffer()
-	by := buf.Bytes()
+	binary.Write(setaBuf, binary.LittleEndian, seta)
+	return setaBuf.Bytes()
+}
 
-	data["notifyOtherPlayers"].(func(game *entities.Game, id int16, msg []byte))(game, player.User.ID, by)
+func parseSeta(args []byte) (float64, bool) {
+	if len(args) != 8 {
+		return 0, false
+	}
+	seta := math.Float64frombits(binary.LittleEndian.Uint64(args))
+	if math.IsNaN(seta) || math.IsInf(seta, 0) {
+		return 0, false
+	}
+	return seta, true
+}
 
-	return nil, nil
+func (g *Grenade) Reset() {
+	g.cancel()
 }
 
-func (g *Grenade) OnWeaponUpdate(game *entities.Game, player *entities.Player, data map[string]interface{}) (map[string]interface{}, error) {
+func (g *Grenade) OnWeaponDown(ctx *entities.WeaponContext, e GrenadePressed) error {
+	//start building the range
+	if ctx.Player.Ink < inkCost {
+		return entities.ErrOutOfInk
+	}
+	if err := g.press(); err != nil {
+		return err
+	}
+
 	//notify the other players
-	setaBuf := &bytes.Buffer{}
-	binary.Write(setaBuf, binary.LittleEndian, float64(data["seta"].(float64)))
-	msg := msgs.WeaponUpdatedMessage{
+	ctx.Notify(msgs.WeaponPressedMessage{
 		WeaponId: id,
-		PlayerId: player.User.ID,
-		Args:     setaBuf.Bytes(),
+		PlayerId: ctx.Player.User.ID,
+		Args:     setaArgs(e.Seta),
+	})
+
+	return nil
+}
+
+func (g *Grenade) OnWeaponUpdate(ctx *entities.WeaponContext, e GrenadeAimed) error {
+	if err := g.aim(); err != nil {
+		return err
 	}
-	buf, _ := msg.Buffer()
-	by := buf.Bytes()
 
-	data["notifyOtherPlayers"].(func(game *entities.Game, id int16, msg []byte))(game, player.User.ID, by)
+	//notify the other players
+	ctx.Notify(msgs.WeaponUpdatedMessage{
+		WeaponId: id,
+		PlayerId: ctx.Player.User.ID,
+		Args:     setaArgs(e.Seta),
+	})
 
-	return nil, nil
+	return nil
 }
 
-func (g *Grenade) OnWeaponUp(game *entities.Game, player *entities.Player, data map[string]interface{}) (map[string]interface{}, error) {
-	x := data["x"].(float64)
-	y := data["y"].(float64)
-	//validate cooldown state
-	if time.Since(g.startCoolDownAt).Seconds() < cooldown {
-		return nil, errors.New("still cooling down")
+func (g *Grenade) OnWeaponUp(ctx *entities.WeaponContext, e GrenadeReleased) error {
+	game, player := ctx.Game, ctx.Player
+	x := e.X
+	y := e.Y
+	//validate the charging state
+	if g.state() != charging {
+		return ErrNotCharging
 	}
 
+	width := float64(game.Settings.MapWidth)
+	height := float64(game.Settings.MapHeight)
+
 	//validate the x,y
-	if y > entities.MapHeight || x > entities.MapWidth {
-		return nil, errors.New("invalid coordinates")
+	if y > height || x > width {
+		g.cancel()
+		notifyCanceled(ctx, id)
+		return errors.New("invalid coordinates")
 	}
 
+	//validate the ink, shooting may have used it up while building the range
+	if player.Ink < inkCost {
+		g.cancel()
+		notifyCanceled(ctx, id)
+		return entities.ErrOutOfInk
+	}
+
+	//stop building the range and start the cooldown
+	charged, err := g.release()
+	if err != nil {
+		notifyCanceled(ctx, id)
+		return err
+	}
+
+	player.UseInk(inkCost)
+
 	//calculate the range
-	buildTime := time.Since(g.startBuildingAt).Seconds()
+	buildTime := charged.Seconds()
 	Range := (rang_constA * buildTime) + rang_constB
 
 	if Range > max_range {
@@ -125,76 +198,24 @@ func (g *Grenade) OnWeaponUp(game *entities.Game, player *entities.Player, data
 	}
 
 	//if x,y are out of map, project them on the map edge
-	x, y = projectIntoMapIfOutside(player, x, y)
+	x, y = projectIntoMapIfOutside(player, x, y, width, height)
 
-	//render the shoot to the map
-	cornerX := int(x) - ((hitBox - 1) / 2)
-	cornerY := int(y) - ((hitBox - 1) / 2)
-
-	for i := 0; i < hitBox; i++ {
-		for j := 0; j < hitBox; j++ {
-			x := cornerX + i
-			y := cornerY + j
-
-			//continu if coordinates are out of map
-			if y > entities.MapHeight || x > entities.MapWidth || y < 0 || x < 0 {
-				continue
-			}
-
-			curr := entities.Get(&game.State.GameMap, x, y)
-
-			//continu if the tile is a wall
-			if curr == entities.WallTile {
-				continue
-			}
-
-			//if the tile is allredy painted, decrement the score of the owner team
-			switch curr {
-			case entities.TeamATile:
-				game.State.ScoreA--
-			case entities.TeamBTile:
-				game.State.ScoreB--
-			}
-
-			//get the tiles new team and increment the score of the new owner team
-			var newTile types.Tile
-			switch player.Team {
-			case entities.TeamA:
-				newTile = entities.TeamATile
-				game.State.ScoreA++
-			case entities.TeamB:
-				newTile = entities.TeamBTile
-				game.State.ScoreB++
-			}
-
-			//paint the tile
-			entities.Set(&game.State.GameMap, x, y, newTile)
-		}
-	}
-
-	//reset the time counter
-	g.startBuildingAt = time.Time{}
-
-	//start the cooldouwn
-	g.startCoolDownAt = time.Now()
+	//throw the grenade, it paints the map when it lands
+	ctx.Game.Spawn(newGrenadeShell(player, x-0.5, y-0.5), 0)
 
 	xyBuf := &bytes.Buffer{}
 	binary.Write(xyBuf, binary.LittleEndian, x)
 	binary.Write(xyBuf, binary.LittleEndian, y)
-	msg := msgs.WeaponReleasedMessage{
+	ctx.Broadcast(msgs.WeaponReleasedMessage{
 		WeaponId: id,
 		PlayerId: player.User.ID,
 		Args:     xyBuf.Bytes(),
-	}
-	buf, _ := msg.Buffer()
-
-	data["notifyOtherPlayers"].(func(game *entities.Game, id int16, msg []byte))(game, player.User.ID, buf.Bytes())
-	player.User.Send(buf.Bytes())
+	})
 
-	return nil, nil
+	return nil
 }
 
-func projectIntoMapIfOutside(player *entities.Player, x float64, y float64) (float64, float64) {
+func projectIntoMapIfOutside(player *entities.Player, x float64, y float64, width float64, height float64) (float64, float64) {
 
 	// the nonBorderCoordinate= samePlayerCoordinate + ( (sameTargetCoordinate - samePlayerCoordinate) * ( playersVerticalProjectionToBorder / playersProjectionToTargetLevelVerticalOnBorder ) );
 	// the BorderCoordinate= borderCoordinate;
@@ -203,14 +224,14 @@ func projectIntoMapIfOutside(player *entities.Player, x float64, y float64) (flo
 		//if x<0, project the x,y on the x=0 line
 		x = 0 + 0.5
 		y = player.Y + 0.5 + ((y - (player.Y + 0.5)) * ((0 - (player.X + 0.5)) / (x - (player.X + 0.5))))
-		if y > 0 && y < entities.MapHeight {
+		if y > 0 && y < height {
 			return x, y
 		}
-	} else if x > entities.MapWidth {
+	} else if x > width {
 		//if x>mapWidth, project the x,y on the x=mapWidth line
-		x = entities.MapWidth - 0.5
-		y = player.Y + 0.5 + ((y - (player.Y + 0.5)) * ((entities.MapWidth - (player.X + 0.5)) / (x - (player.X + 0.5))))
-		if y > 0 && y < entities.MapHeight {
+		x = width - 0.5
+		y = player.Y + 0.5 + ((y - (player.Y + 0.5)) * ((width - (player.X + 0.5)) / (x - (player.X + 0.5))))
+		if y > 0 && y < height {
 			return x, y
 		}
 	}
@@ -221,10 +242,10 @@ func projectIntoMapIfOutside(player *entities.Player, x float64, y float64) (flo
 		y = 0 + 0.5
 
 		return x, y
-	} else if y > entities.MapHeight {
+	} else if y > height {
 		//if y>mapHeight, project the x,y on the y=mapHeight line
-		x = player.X + 0.5 + ((x - (player.X + 0.5)) * ((entities.MapHeight - (player.Y + 0.5)) / (y - (player.Y + 0.5))))
-		y = entities.MapHeight - 0.5
+		x = player.X + 0.5 + ((x - (player.X + 0.5)) * ((height - (player.Y + 0.5)) / (y - (player.Y + 0.5))))
+		y = height - 0.5
 
 		return x, y
 	}
@@ -232,54 +253,119 @@ func projectIntoMapIfOutside(player *entities.Player, x float64, y float64) (flo
 	return x, y
 }
 
-func (g *Grenade) ParseWeaponDownMessage(message msgs.GenericMessage) (map[string]interface{}, bool) {
-	if message.Type != msgs.MSG_WEAPONDOWN {
-		return map[string]interface{}{}, false
+func (g *Grenade) ParseWeaponDownMessage(args []byte) (entities.WeaponEvent, bool) {
+	seta, ok := parseSeta(args)
+	if !ok {
+		return nil, false
 	}
+	return GrenadePressed{grenade: g, Seta: seta}, true
+}
 
-	args, ok := entities.CheckWeaponId(id, message.Args)
+func (g *Grenade) ParseWeaponUpdateMessage(args []byte) (entities.WeaponEvent, bool) {
+	seta, ok := parseSeta(args)
 	if !ok {
-		return map[string]interface{}{}, false
+		return nil, false
 	}
+	return GrenadeAimed{grenade: g, Seta: seta}, true
+}
 
-	if len(args) != 8 {
-		return map[string]interface{}{}, false
+func (g *Grenade) ParseWeaponUpMessage(args []byte) (entities.WeaponEvent, bool) {
+	if len(args) != 16 {
+		return nil, false
+	}
+	var x float64 = math.Float64frombits(binary.LittleEndian.Uint64(args[:8]))
+	var y float64 = math.Float64frombits(binary.LittleEndian.Uint64(args[8:]))
+	if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+		return nil, false
 	}
-	var seta float64 = math.Float64frombits(binary.LittleEndian.Uint64(args[:8]))
-	return map[string]interface{}{"seta": seta}, true
+	return GrenadeReleased{grenade: g, X: x, Y: y}, true
 }
 
-func (g *Grenade) ParseWeaponUpdateMessage(message msgs.GenericMessage) (map[string]interface{}, bool) {
-	if message.Type != msgs.MSG_WEAPONUPDATE {
-		return map[string]interface{}{}, false
-	}
+// GrenadeShell is a thrown grenade in flight. It bounces off walls and the map
+// edges, and paints its hitbox where it lands.
+type GrenadeShell struct {
+	team      types.TeamID
+	x, y      float64
+	vx, vy    float64 // tiles per tick
+	ticksLeft int
+}
 
-	args, ok := entities.CheckWeaponId(id, message.Args)
-	if !ok {
-		return map[string]interface{}{}, false
+// newGrenadeShell throws a grenade from the player to x, y. The further the
+// target, the longer the flight.
+func newGrenadeShell(player *entities.Player, x, y float64) *GrenadeShell {
+	distance := math.Hypot(x-player.X, y-player.Y)
+	ticks := int(math.Ceil(distance / grenadeSpeed / entities.GameTick.Seconds()))
+	if ticks < 1 {
+		ticks = 1
 	}
-
-	if len(args) != 8 {
-		return map[string]interface{}{}, false
+	return &GrenadeShell{
+		team:      player.Team,
+		x:         player.X,
+		y:         player.Y,
+		vx:        (x - player.X) / float64(ticks),
+		vy:        (y - player.Y) / float64(ticks),
+		ticksLeft: ticks,
 	}
-	var seta float64 = math.Float64frombits(binary.LittleEndian.Uint64(args[:8]))
-	return map[string]interface{}{"seta": seta}, true
 }
 
-func (g *Grenade) ParseWeaponUpMessage(message msgs.GenericMessage) (map[string]interface{}, bool) {
-	if message.Type != msgs.MSG_WEAPONUP {
-		return map[string]interface{}{}, false
+func (s *GrenadeShell) Kind() types.EntityKind {
+	return entities.GrenadeEntity
+}
+
+func (s *GrenadeShell) Team() types.TeamID {
+	return s.team
+}
+
+func (s *GrenadeShell) Position() (float64, float64) {
+	return s.x, s.y
+}
+
+// SpawnArgs holds the shell's velocity in tiles per second and the seconds
+// left until it lands
+func (s *GrenadeShell) SpawnArgs() []byte {
+	perSecond := 1 / entities.GameTick.Seconds()
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, s.vx*perSecond)
+	binary.Write(buf, binary.LittleEndian, s.vy*perSecond)
+	binary.Write(buf, binary.LittleEndian, float64(s.ticksLeft)*entities.GameTick.Seconds())
+	return buf.Bytes()
+}
+
+// Update moves the shell by a tick, and paints the hitbox around the tile it
+// lands on. The painted tiles reach the players in the tick's TilesMessage.
+func (s *GrenadeShell) Update(g *entities.Game) entities.EntityStatus {
+	m := &g.State.GameMap
+	blocked := func(x, y float64) bool {
+		return entities.Get(m, int(math.Floor(x+0.5)), int(math.Floor(y+0.5))) == entities.WallTile
 	}
 
-	args, ok := entities.CheckWeaponId(id, message.Args)
-	if !ok {
-		return map[string]interface{}{}, false
+	x, y := s.x+s.vx, s.y+s.vy
+	if blocked(x, s.y) {
+		s.vx = -s.vx
+		x = s.x + s.vx
 	}
+	if blocked(x, y) {
+		s.vy = -s.vy
+		y = s.y + s.vy
+	}
+	if blocked(x, y) {
+		// wedged in a corner, drop where it is
+		s.vx, s.vy = 0, 0
+		x, y = s.x, s.y
+	}
+	s.x, s.y = x, y
 
-	if len(args) != 16 {
-		return map[string]interface{}{}, false
+	s.ticksLeft--
+	if s.ticksLeft > 0 {
+		return entities.EntityAlive
 	}
-	var x float64 = math.Float64frombits(binary.LittleEndian.Uint64(args[:8]))
-	var y float64 = math.Float64frombits(binary.LittleEndian.Uint64(args[8:]))
-	return map[string]interface{}{"x": x, "y": y}, true
+
+	tileX := int(math.Floor(s.x + 0.5))
+	tileY := int(math.Floor(s.y + 0.5))
+	for i := 0; i < hitBox; i++ {
+		for j := 0; j < hitBox; j++ {
+			g.Paint(tileX-(hitBox-1)/2+i, tileY-(hitBox-1)/2+j, s.team)
+		}
+	}
+	return entities.EntityTriggered
 }
@@ -0,0 +1,137 @@
+package wepons
+
+import (
+	"online-game/entities"
+	"online-game/types"
+	"testing"
+)
+
+// testGame is a game on a map drawn with '#' for walls and '.' for empty
+// tiles
+func testGame(rows ...string) *entities.Game {
+	m := types.GameMap{Width: len(rows[0]), Height: len(rows)}
+	m.Tiles = make([]types.Tile, m.Width*m.Height)
+	for y, row := range rows {
+		for x := range row {
+			if row[x] == '#' {
+				m.Tiles[y*m.Width+x] = entities.WallTile
+			} else {
+				m.Tiles[y*m.Width+x] = entities.EmptyTile
+			}
+		}
+	}
+	return &entities.Game{State: types.GameState{GameMap: m}}
+}
+
+// painted is the map drawn back, with 'a' for the tiles team A painted
+func painted(g *entities.Game) []string {
+	m := &g.State.GameMap
+	rows := make([]string, m.Height)
+	for y := range rows {
+		row := make([]byte, m.Width)
+		for x := range row {
+			switch entities.Get(m, x, y) {
+			case entities.WallTile:
+				row[x] = '#'
+			case entities.TeamATile:
+				row[x] = 'a'
+			default:
+				row[x] = '.'
+			}
+		}
+		rows[y] = string(row)
+	}
+	return rows
+}
+
+// land updates the shell until it stops, failing if it does not when
+// expected
+func land(t *testing.T, g *entities.Game, s *GrenadeShell) {
+	ticks := s.ticksLeft
+	for tick := 1; tick < ticks; tick++ {
+		if status := s.Update(g); status != entities.EntityAlive {
+			t.Fatalf("tick %d: status %v, want alive", tick, status)
+		}
+	}
+	if status := s.Update(g); status != entities.EntityTriggered {
+		t.Fatalf("landing tick: status %v, want triggered", status)
+	}
+}
+
+func checkPainted(t *testing.T, g *entities.Game, want []string) {
+	got := painted(g)
+	for y := range want {
+		if got[y] != want[y] {
+			t.Fatalf("painted\n%v\nwant\n%v", got, want)
+		}
+	}
+}
+
+func TestGrenadeShellBounce(t *testing.T) {
+	g := testGame(
+		".......",
+		".......",
+		"....#..",
+		".......",
+		".......",
+	)
+	// thrown right at the wall, it bounces back and lands at the left edge
+	s := &GrenadeShell{team: entities.TeamA, x: 1, y: 2, vx: 1, ticksLeft: 5}
+	land(t, g, s)
+
+	if s.x != 0 || s.y != 2 {
+		t.Fatalf("landed at (%v, %v), want (0, 2)", s.x, s.y)
+	}
+	checkPainted(t, g, []string{
+		".......",
+		"aa.....",
+		"aa..#..",
+		"aa.....",
+		".......",
+	})
+	if g.State.ScoreA != 6 {
+		t.Fatalf("score = %d, want 6", g.State.ScoreA)
+	}
+}
+
+func TestGrenadeShellWedged(t *testing.T) {
+	g := testGame(
+		"#####",
+		"#.###",
+		"#####",
+	)
+	// every way out is a wall, it drops where it is
+	s := &GrenadeShell{team: entities.TeamA, x: 1, y: 1, vx: 1, vy: 1, ticksLeft: 2}
+	land(t, g, s)
+
+	if s.x != 1 || s.y != 1 || s.vx != 0 || s.vy != 0 {
+		t.Fatalf("landed at (%v, %v) moving (%v, %v), want (1, 1) at rest", s.x, s.y, s.vx, s.vy)
+	}
+	checkPainted(t, g, []string{
+		"#####",
+		"#a###",
+		"#####",
+	})
+}
+
+func TestGrenadeShellEdgeBounce(t *testing.T) {
+	g := testGame(
+		".....",
+		".....",
+		".....",
+		".....",
+	)
+	// thrown up past the top edge, it comes back down
+	s := &GrenadeShell{team: entities.TeamA, x: 2, y: 1, vy: -1, ticksLeft: 3}
+	land(t, g, s)
+
+	if s.x != 2 || s.y != 2 {
+		t.Fatalf("landed at (%v, %v), want (2, 2)", s.x, s.y)
+	}
+	checkPainted(t, g, []string{
+		".....",
+		".aaa.",
+		".aaa.",
+		".aaa.",
+	})
+}
@@ -0,0 +1,158 @@
+package wepons
+
+import (
+	"errors"
+	"math"
+	"online-game/entities"
+	"online-game/types"
+	"time"
+)
+
+const mineId = entities.MineId
+const mineName = "Mine"
+const mineCooldown = 3
+const mineInk = 25
+const mineLifetime = 30 * time.Second
+const mineMaxPlaced = 3     // mines a player can have on the map at once
+const mineTriggerRadius = 1 // tiles, an enemy this close sets the mine off
+const mineBlastRadius = 3   // tiles painted when it goes off
+
+// Mine drops a paint mine on the player's tile when released. The mine waits
+// for an enemy to step on it, then paints the tiles around it in its owner's
+// color.
+type Mine struct {
+	cycle
+}
+
+func init() {
+	Register(mineId, func() entities.Weapon {
+		return &Mine{cycle{cooldown: mineCooldown * time.Second}}
+	})
+}
+
+// MineDropped drops a mine where the player stands
+type MineDropped struct {
+	mine *Mine
+}
+
+func (e MineDropped) Apply(ctx *entities.WeaponContext) error {
+	return e.mine.OnWeaponUp(ctx, e)
+}
+
+func (m *Mine) Id() types.WeaponId {
+	return mineId
+}
+
+func (m *Mine) Name() string {
+	return mineName
+}
+
+func (m *Mine) GetCooldown() int {
+	return mineCooldown
+}
+
+func (m *Mine) Stringify() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     m.Name(),
+		"cooldown": m.GetCooldown(),
+	}
+}
+
+func (m *Mine) OnWeaponUp(ctx *entities.WeaponContext, e MineDropped) error {
+	if m.state() == coolingDown {
+		return ErrCoolingDown
+	}
+
+	player := ctx.Player
+	placed := ctx.Game.CountEntities(entities.MineEntity, func(e entities.Entity) bool {
+		mine, ok := e.(*PaintMine)
+		return ok && mine.owner == player.User.ID
+	})
+	if placed >= mineMaxPlaced {
+		return errors.New("too many mines placed")
+	}
+	if player.Ink < mineInk {
+		return entities.ErrOutOfInk
+	}
+
+	x := int(player.X + 0.5)
+	y := int(player.Y + 0.5)
+	if entities.Get(&ctx.Game.State.GameMap, x, y) == entities.WallTile {
+		return errors.New("cannot place a mine on a wall")
+	}
+
+	m.fire()
+	player.UseInk(mineInk)
+	ctx.Game.Spawn(&PaintMine{
+		owner: player.User.ID,
+		team:  player.Team,
+		x:     x,
+		y:     y,
+	}, mineLifetime)
+
+	return nil
+}
+
+// the mine is dropped on release, pressing it does nothing
+func (m *Mine) ParseWeaponDownMessage(args []byte) (entities.WeaponEvent, bool) {
+	return nil, false
+}
+
+func (m *Mine) ParseWeaponUpdateMessage(args []byte) (entities.WeaponEvent, bool) {
+	return nil, false
+}
+
+func (m *Mine) ParseWeaponUpMessage(args []byte) (entities.WeaponEvent, bool) {
+	if len(args) != 0 {
+		return nil, false
+	}
+	return MineDropped{mine: m}, true
+}
+
+// PaintMine is a mine placed on the map
+type PaintMine struct {
+	owner int16
+	team  types.TeamID
+	x, y  int
+}
+
+func (p *PaintMine) Kind() types.EntityKind {
+	return entities.MineEntity
+}
+
+func (p *PaintMine) Team() types.TeamID {
+	return p.team
+}
+
+func (p *PaintMine) Position() (float64, float64) {
+	return float64(p.x), float64(p.y)
+}
+
+// SpawnArgs is empty, a mine needs nothing past its position
+func (p *PaintMine) SpawnArgs() []byte {
+	return nil
+}
+
+// Update sets the mine off once an enemy gets close enough, painting the tiles
+// within the blast. The painted tiles reach the players in the tick's
+// TilesMessage.
+func (p *PaintMine) Update(g *entities.Game) entities.EntityStatus {
+	for _, player := range g.Players {
+		if player.Team == p.team {
+			continue
+		}
+		if math.Hypot(player.X-float64(p.x), player.Y-float64(p.y)) > mineTriggerRadius {
+			continue
+		}
+
+		for y := p.y - mineBlastRadius; y <= p.y+mineBlastRadius; y++ {
+			for x := p.x - mineBlastRadius; x <= p.x+mineBlastRadius; x++ {
+				if math.Hypot(float64(x-p.x), float64(y-p.y)) <= mineBlastRadius {
+					g.Paint(x, y, p.team)
+				}
+			}
+		}
+		return entities.EntityTriggered
+	}
+	return entities.EntityAlive
+}
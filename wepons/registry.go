@@ -0,0 +1,52 @@
+package wepons
+
+import (
+	"fmt"
+	"online-game/entities"
+	"online-game/types"
+)
+
+// Constructor creates a fresh weapon for a player
+type Constructor func() entities.Weapon
+
+var registry = map[types.WeaponId]Constructor{}
+
+// Register makes a weapon available under its id, weapons register
+// themselves from init
+func Register(id types.WeaponId, constructor Constructor) {
+	if _, ok := registry[id]; ok {
+		panic(fmt.Sprintf("weapon %d registered twice", id))
+	}
+	registry[id] = constructor
+}
+
+// New creates the weapon registered under id
+func New(id types.WeaponId) (entities.Weapon, error) {
+	constructor, ok := registry[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown weapon %d", id)
+	}
+	return constructor(), nil
+}
+
+// DefaultLoadout creates the weapons players start with, the first ones the
+// room allows. A room allowing a single weapon fills both slots with it.
+func DefaultLoadout(settings entities.MatchSettings) entities.Loadout {
+	var loadout entities.Loadout
+	slot := 0
+	for id := types.WeaponId(0); id < entities.WeaponCount && slot < len(loadout); id++ {
+		constructor, ok := registry[id]
+		if ok && settings.Allows(id) {
+			loadout[slot] = constructor()
+			slot++
+		}
+	}
+	for ; slot < len(loadout); slot++ {
+		if slot == 0 {
+			loadout[slot] = registry[entities.GrenadeId]()
+		} else {
+			loadout[slot] = registry[loadout[0].Id()]()
+		}
+	}
+	return loadout
+}
@@ -0,0 +1,144 @@
+package wepons
+
+import (
+	"online-game/entities"
+	"online-game/msgs"
+	"online-game/types"
+)
+
+const rollerId = entities.RollerId
+const rollerName = "Roller"
+const rollerInkPerTile = 1.5  // player ink used by every tile painted
+const rollerSpeedFactor = 0.6 // movement speed while rolling
+
+// Roller paints every tile its player crosses while it is held down, for as
+// long as their ink lasts
+type Roller struct {
+	cycle // charging while rolling
+}
+
+func init() {
+	Register(rollerId, func() entities.Weapon { return &Roller{} })
+}
+
+func (r *Roller) rolling() bool {
+	return r.state() == charging
+}
+
+// RollerPressed puts the roller down
+type RollerPressed struct {
+	roller *Roller
+}
+
+// RollerReleased lifts the roller
+type RollerReleased struct {
+	roller *Roller
+}
+
+func (e RollerPressed) Apply(ctx *entities.WeaponContext) error {
+	return e.roller.OnWeaponDown(ctx, e)
+}
+
+func (e RollerReleased) Apply(ctx *entities.WeaponContext) error {
+	return e.roller.OnWeaponUp(ctx, e)
+}
+
+func (r *Roller) Id() types.WeaponId {
+	return rollerId
+}
+
+func (r *Roller) Name() string {
+	return rollerName
+}
+
+func (r *Roller) GetCooldown() int {
+	return 0
+}
+
+func (r *Roller) Stringify() map[string]interface{} {
+	return map[string]interface{}{
+		"type":    r.Name(),
+		"rolling": r.rolling(),
+	}
+}
+
+func (r *Roller) SpeedFactor() float64 {
+	if r.rolling() {
+		return rollerSpeedFactor
+	}
+	return 1
+}
+
+func (r *Roller) Reset() {
+	r.cancel()
+}
+
+func (r *Roller) OnWeaponDown(ctx *entities.WeaponContext, e RollerPressed) error {
+	if ctx.Player.Ink < rollerInkPerTile {
+		return entities.ErrOutOfInk
+	}
+	if err := r.press(); err != nil {
+		return err
+	}
+
+	ctx.Broadcast(msgs.WeaponPressedMessage{
+		WeaponId: rollerId,
+		PlayerId: ctx.Player.User.ID,
+	})
+
+	return nil
+}
+
+func (r *Roller) OnWeaponUp(ctx *entities.WeaponContext, e RollerReleased) error {
+	if !r.rolling() {
+		// already stopped when it ran out of ink
+		return nil
+	}
+	r.stop(ctx)
+	return nil
+}
+
+// Update paints the tile under the player while rolling. The painted tiles
+// reach the players in the tick's TilesMessage.
+func (r *Roller) Update(ctx *entities.WeaponContext) {
+	if !r.rolling() {
+		return
+	}
+
+	player := ctx.Player
+	x := int(player.X + 0.5)
+	y := int(player.Y + 0.5)
+	if ctx.Game.Paint(x, y, player.Team) {
+		player.UseInk(rollerInkPerTile)
+	}
+	if player.Ink < rollerInkPerTile {
+		r.stop(ctx)
+	}
+}
+
+func (r *Roller) stop(ctx *entities.WeaponContext) {
+	r.release()
+	ctx.Broadcast(msgs.WeaponReleasedMessage{
+		WeaponId: rollerId,
+		PlayerId: ctx.Player.User.ID,
+	})
+}
+
+func (r *Roller) ParseWeaponDownMessage(args []byte) (entities.WeaponEvent, bool) {
+	if len(args) != 0 {
+		return nil, false
+	}
+	return RollerPressed{roller: r}, true
+}
+
+// the roller has no aim to update
+func (r *Roller) ParseWeaponUpdateMessage(args []byte) (entities.WeaponEvent, bool) {
+	return nil, false
+}
+
+func (r *Roller) ParseWeaponUpMessage(args []byte) (entities.WeaponEvent, bool) {
+	if len(args) != 0 {
+		return nil, false
+	}
+	return RollerReleased{roller: r}, true
+}